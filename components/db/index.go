@@ -0,0 +1,279 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	LookupEquals = "equals"
+	LookupRange  = "range"
+)
+
+type KeyValueIndex struct {
+	Field string `json:"field" required:"true" title:"Field" description:"Document field, nested fields are separated by dots" minLength:"1"`
+}
+
+// kinds of indexed values in their sort order
+const (
+	indexKindNull = iota
+	indexKindBool
+	indexKindNumber
+	indexKindString
+)
+
+type indexValue struct {
+	kind int
+	b    bool
+	num  float64
+	str  string
+}
+
+// toIndexValue converts scalar document value, objects and arrays are not indexed
+func toIndexValue(v any) (indexValue, bool) {
+	switch vv := v.(type) {
+	case nil:
+		return indexValue{kind: indexKindNull}, true
+	case bool:
+		return indexValue{kind: indexKindBool, b: vv}, true
+	case float64:
+		return indexValue{kind: indexKindNumber, num: vv}, true
+	case string:
+		return indexValue{kind: indexKindString, str: vv}, true
+	case map[string]any, []any:
+		return indexValue{}, false
+	}
+	// other numeric types of incoming messages are normalized the way stored documents are decoded
+	data, err := json.Marshal(v)
+	if err != nil {
+		return indexValue{}, false
+	}
+	var decoded any
+	if err = json.Unmarshal(data, &decoded); err != nil {
+		return indexValue{}, false
+	}
+	if _, ok := decoded.(float64); !ok {
+		return indexValue{}, false
+	}
+	return toIndexValue(decoded)
+}
+
+func compareIndexValues(a, b indexValue) int {
+	if a.kind != b.kind {
+		return a.kind - b.kind
+	}
+	switch a.kind {
+	case indexKindBool:
+		switch {
+		case a.b == b.b:
+			return 0
+		case !a.b:
+			return -1
+		}
+		return 1
+	case indexKindNumber:
+		switch {
+		case a.num < b.num:
+			return -1
+		case a.num > b.num:
+			return 1
+		}
+		return 0
+	case indexKindString:
+		return strings.Compare(a.str, b.str)
+	}
+	return 0
+}
+
+// fieldValue finds value of dot separated field
+func fieldValue(doc map[string]any, field string) (any, bool) {
+	var current any = doc
+	for _, name := range strings.Split(field, ".") {
+		m, ok := current.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if current, ok = m[name]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+type indexEntry struct {
+	value indexValue
+	key   string
+}
+
+// kvIndex keeps primary keys sorted by field value, so exact and range lookups are binary searches
+type kvIndex struct {
+	field   string
+	entries []indexEntry
+}
+
+func (i *kvIndex) entry(key string, doc map[string]any) (indexEntry, bool) {
+	v, ok := fieldValue(doc, i.field)
+	if !ok {
+		return indexEntry{}, false
+	}
+	value, ok := toIndexValue(v)
+	return indexEntry{value: value, key: key}, ok
+}
+
+// search finds position of the entry or where it should be inserted
+func (i *kvIndex) search(e indexEntry) int {
+	return sort.Search(len(i.entries), func(n int) bool {
+		if c := compareIndexValues(i.entries[n].value, e.value); c != 0 {
+			return c > 0
+		}
+		return i.entries[n].key >= e.key
+	})
+}
+
+func (i *kvIndex) add(key string, doc map[string]any) {
+	e, ok := i.entry(key, doc)
+	if !ok {
+		return
+	}
+	n := i.search(e)
+	i.entries = append(i.entries, indexEntry{})
+	copy(i.entries[n+1:], i.entries[n:])
+	i.entries[n] = e
+}
+
+func (i *kvIndex) remove(key string, doc map[string]any) {
+	e, ok := i.entry(key, doc)
+	if !ok {
+		return
+	}
+	n := i.search(e)
+	if n < len(i.entries) && i.entries[n].key == key {
+		i.entries = append(i.entries[:n], i.entries[n+1:]...)
+	}
+}
+
+// lookup returns primary keys with values between inclusive bounds, nil bound means unbounded within the type of the other one
+func (i *kvIndex) lookup(from *indexValue, to *indexValue) []string {
+	start := 0
+	switch {
+	case from != nil:
+		start = sort.Search(len(i.entries), func(n int) bool {
+			return compareIndexValues(i.entries[n].value, *from) >= 0
+		})
+	case to != nil:
+		start = sort.Search(len(i.entries), func(n int) bool {
+			return i.entries[n].value.kind >= to.kind
+		})
+	}
+	end := len(i.entries)
+	switch {
+	case to != nil:
+		end = sort.Search(len(i.entries), func(n int) bool {
+			return compareIndexValues(i.entries[n].value, *to) > 0
+		})
+	case from != nil:
+		end = sort.Search(len(i.entries), func(n int) bool {
+			return i.entries[n].value.kind > from.kind
+		})
+	}
+	keys := make([]string, 0, max(end-start, 0))
+	for n := start; n < end; n++ {
+		keys = append(keys, i.entries[n].key)
+	}
+	return keys
+}
+
+// kvIndexes holds declared indexes and the primary key one
+type kvIndexes struct {
+	lock    *sync.RWMutex
+	byField map[string]*kvIndex
+}
+
+func newIndexes() *kvIndexes {
+	return &kvIndexes{
+		lock:    &sync.RWMutex{},
+		byField: make(map[string]*kvIndex),
+	}
+}
+
+// rebuild indexes fields of all records
+func (x *kvIndexes) rebuild(fields []string, records map[string][]byte) error {
+	byField := make(map[string]*kvIndex, len(fields))
+	for _, field := range fields {
+		byField[field] = &kvIndex{field: field}
+	}
+	for key, data := range records {
+		doc := make(map[string]any)
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("unable to decode record %s: %v", key, err)
+		}
+		for _, idx := range byField {
+			idx.add(key, doc)
+		}
+	}
+
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.byField = byField
+	return nil
+}
+
+// update replaces entries of the previous document version, nil document means there is no such version
+func (x *kvIndexes) update(key string, prev map[string]any, next map[string]any) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+
+	for _, idx := range x.byField {
+		if prev != nil {
+			idx.remove(key, prev)
+		}
+		if next != nil {
+			idx.add(key, next)
+		}
+	}
+}
+
+// lookup finds primary keys by exact value, null included, or by range of values of the same type
+func (x *kvIndexes) lookup(field string, exact bool, equals any, from any, to any) ([]string, error) {
+	x.lock.RLock()
+	defer x.lock.RUnlock()
+
+	idx, ok := x.byField[field]
+	if !ok {
+		return nil, fmt.Errorf("field %s is not indexed", field)
+	}
+
+	if exact {
+		value, ok := toIndexValue(equals)
+		if !ok {
+			return nil, fmt.Errorf("invalid lookup value %v", equals)
+		}
+		return idx.lookup(&value, &value), nil
+	}
+
+	bound := func(v any) (*indexValue, error) {
+		if v == nil {
+			return nil, nil
+		}
+		value, ok := toIndexValue(v)
+		if !ok {
+			return nil, fmt.Errorf("invalid lookup value %v", v)
+		}
+		return &value, nil
+	}
+	lower, err := bound(from)
+	if err != nil {
+		return nil, err
+	}
+	upper, err := bound(to)
+	if err != nil {
+		return nil, err
+	}
+	if lower != nil && upper != nil && lower.kind != upper.kind {
+		return nil, fmt.Errorf("range bounds %v and %v are of different types", from, to)
+	}
+	return idx.lookup(lower, upper), nil
+}
@@ -0,0 +1,56 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_kvIndex_lookup(t *testing.T) {
+	idx := &kvIndex{field: "user.age"}
+	docs := map[string]map[string]any{
+		"a": {"user": map[string]any{"age": float64(30)}},
+		"b": {"user": map[string]any{"age": float64(20)}},
+		"c": {"user": map[string]any{"age": float64(30)}},
+		"d": {"user": map[string]any{"age": "unknown"}},
+		"e": {"user": map[string]any{"name": "no age"}},
+		"f": {"user": map[string]any{"age": nil}},
+	}
+	for key, doc := range docs {
+		idx.add(key, doc)
+	}
+
+	value := func(v any) *indexValue {
+		iv, _ := toIndexValue(v)
+		return &iv
+	}
+
+	tests := []struct {
+		name string
+		from *indexValue
+		to   *indexValue
+		want []string
+	}{
+		{name: "all", want: []string{"f", "b", "a", "c", "d"}},
+		{name: "exact", from: value(30), to: value(30), want: []string{"a", "c"}},
+		{name: "exact int types", from: value(int64(20)), to: value(uint8(20)), want: []string{"b"}},
+		{name: "range", from: value(21), to: value(100), want: []string{"a", "c"}},
+		{name: "open upper bound", from: value(25), want: []string{"a", "c"}},
+		{name: "open lower bound", to: value(20), want: []string{"b"}},
+		{name: "open bound of strings", from: value("a"), want: []string{"d"}},
+		{name: "null", from: value(nil), to: value(nil), want: []string{"f"}},
+		{name: "no match", from: value(31), to: value(40), want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := idx.lookup(tt.from, tt.to); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("lookup() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	idx.remove("a", docs["a"])
+	idx.remove("e", docs["e"])
+	if got := idx.lookup(value(30), value(30)); !reflect.DeepEqual(got, []string{"c"}) {
+		t.Errorf("lookup() after remove = %v, want [c]", got)
+	}
+}
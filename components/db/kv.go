@@ -8,6 +8,9 @@ import (
 	"github.com/spyzhov/ajson"
 	"github.com/swaggest/jsonschema-go"
 	"github.com/tiny-systems/module/module"
+	"github.com/tiny-systems/module/registry"
+	"sort"
+	"sync"
 )
 
 type KeyValueQueryRequestContext any
@@ -43,23 +46,45 @@ type KeyValueStoreSettings struct {
 	Document           KeyValueStoreDocument `json:"document" type:"object" required:"true" title:"Document" description:"Structure of the object will be used to store incoming messages. Values are arbitrary. Make sure the document has primary key defined below." configurable:"true"`
 	PrimaryKey         string                `json:"primaryKey" title:"Primary key" required:"true" default:"id"`
 	EnableStoreAckPort bool                  `json:"enableStoreResultPort" required:"true" title:"Enable Store Ack Port" default:"false" description:"Emits information if message was stored or not"`
+	Storage            string                `json:"storage" required:"true" title:"Storage" enum:"memory,bolt" enumTitles:"Memory,Disk" default:"memory" description:"Memory storage loses records on restart. Disk storage keeps them in a local database file and restores them on start. Switching between memory and disk carries current records over, replacing stored records with the same key"`
+	Path               string                `json:"path" title:"File path" description:"Database file of disk storage, created if missing"`
+	Indexes            []KeyValueIndex       `json:"indexes" title:"Indexes" description:"Document fields records are looked up by without scanning all of them. Primary key is always indexed"`
 }
 
 type KeyValueStore struct {
 	records  cmap.ConcurrentMap[string, []byte]
 	settings KeyValueStoreSettings
+
+	storage kvStorage
+	indexes *kvIndexes
+	// lock keeps storage, records and indexes in the same order of writes, guards settings and records swapped by configure
+	lock *sync.Mutex
 }
 
 type KeyValueQueryRequest struct {
 	Context KeyValueQueryRequestContext `json:"context" configurable:"true" title:"Context"`
-	Query   string                      `json:"query,omitempty" required:"true" title:"Query"`
+	Query   string                      `json:"query,omitempty" title:"Query" description:"JSONPath expression matching documents, e.g. $.documentProperty == 1. Applied to index lookup results if index is set"`
+
+	Index  string `json:"index,omitempty" title:"Index" description:"Primary key or indexed field to look up by"`
+	Lookup string `json:"lookup,omitempty" title:"Lookup" enum:"equals,range" enumTitles:"Equals,Range" description:"Exact lookup finds null when equals is empty. Range lookup is used if not set and equals is empty"`
+	Equals any    `json:"equals,omitempty" configurable:"true" title:"Equals" description:"Exact value to look up"`
+	From   any    `json:"from,omitempty" configurable:"true" title:"From" description:"Lower bound of range lookup, inclusive. Without upper bound values of the same type are matched"`
+	To     any    `json:"to,omitempty" configurable:"true" title:"To" description:"Upper bound of range lookup, inclusive. Without lower bound values of the same type are matched"`
+
+	SortBy    string `json:"sortBy,omitempty" title:"Sort by" description:"Document field to sort by. Index lookups are sorted by the index, other queries by primary key" colSpan:"col-span-6"`
+	SortDesc  bool   `json:"sortDesc,omitempty" title:"Descending" colSpan:"col-span-6"`
+	Limit     int    `json:"limit,omitempty" title:"Limit" description:"Maximum number of documents, zero means unlimited" minimum:"0" colSpan:"col-span-6"`
+	Offset    int    `json:"offset,omitempty" title:"Offset" minimum:"0" colSpan:"col-span-6"`
+	CountOnly bool   `json:"countOnly,omitempty" title:"Count only" description:"Return number of matches without documents"`
 }
 
 type KeyValueQueryResult struct {
-	Context  KeyValueQueryRequestContext `json:"context"`
-	Document KeyValueStoreDocument       `json:"document"`
-	Found    bool                        `json:"found"`
-	Query    string                      `json:"query"`
+	Context   KeyValueQueryRequestContext `json:"context"`
+	Document  KeyValueStoreDocument       `json:"document" description:"First of the documents"`
+	Documents []KeyValueStoreDocument     `json:"documents" description:"Matching documents within limit and offset"`
+	Count     int                         `json:"count" description:"Number of all matches"`
+	Found     bool                        `json:"found"`
+	Query     string                      `json:"query"`
 }
 
 type KeyValueStoreRequest struct {
@@ -76,7 +101,7 @@ func (k *KeyValueStore) GetInfo() module.ComponentInfo {
 	return module.ComponentInfo{
 		Name:        "db_kv",
 		Description: "Key-Value Storage",
-		Info:        "In memory key valued store. Requires incoming message to be an object with non empty field ID",
+		Info:        "Key valued store kept in memory or in a local database file. Requires incoming message to be an object with non empty field ID",
 		Tags:        []string{"kv", "db", "storage"},
 	}
 }
@@ -96,8 +121,7 @@ func (k *KeyValueStore) Handle(ctx context.Context, output module.Handler, port
 		if _, ok := in.Document[in.PrimaryKey]; !ok {
 			return fmt.Errorf("primary key is missing in the document")
 		}
-		k.settings = in
-		return nil
+		return k.configure(in)
 	}
 
 	if port == PortStore {
@@ -105,7 +129,9 @@ func (k *KeyValueStore) Handle(ctx context.Context, output module.Handler, port
 		if !ok {
 			return fmt.Errorf("invalid store message")
 		}
-		pkVal, ok := in.Document[k.settings.PrimaryKey]
+		settings, _ := k.snapshot()
+
+		pkVal, ok := in.Document[settings.PrimaryKey]
 		if !ok {
 			return fmt.Errorf("no primary key defined")
 		}
@@ -118,15 +144,11 @@ func (k *KeyValueStore) Handle(ctx context.Context, output module.Handler, port
 			return fmt.Errorf("unable to encode message to store: %v", err)
 		}
 
-		if in.Operation == OpStore {
-			k.records.Set(pkValStr, data)
-		} else if in.Operation == OptDelete {
-			k.records.Remove(pkValStr)
-		} else {
-			return fmt.Errorf("unknown operation: %s", in.Operation)
+		if err = k.write(in.Operation, pkValStr, data); err != nil {
+			return err
 		}
 
-		if k.settings.EnableStoreAckPort {
+		if settings.EnableStoreAckPort {
 			return output(ctx, PortStoreAck, KeyValueStoreResult{
 				Request: in,
 			})
@@ -142,46 +164,224 @@ func (k *KeyValueStore) Handle(ctx context.Context, output module.Handler, port
 	if !ok {
 		return fmt.Errorf("invalid query message")
 	}
-	if in.Query == "" {
+	if in.Query == "" && in.Index == "" {
 		return fmt.Errorf("empty query")
 	}
 
-	for _, key := range k.records.Keys() {
-		data, _ := k.records.Get(key)
-		node, err := ajson.Unmarshal(data)
-		if err != nil {
-			return fmt.Errorf("unable to encode stored message")
+	_, records := k.snapshot()
+
+	var keys []string
+	if in.Index != "" {
+		exact := in.Equals != nil
+		if in.Lookup != "" {
+			exact = in.Lookup == LookupEquals
 		}
-		jsonPathResult, err := ajson.Eval(node, in.Query)
-		if err != nil {
-			return fmt.Errorf("unable to eval query: %v", err)
+		var err error
+		if keys, err = k.indexes.lookup(in.Index, exact, in.Equals, in.From, in.To); err != nil {
+			return err
 		}
-		v, err := jsonPathResult.Unpack()
-		if err != nil {
-			return fmt.Errorf("unable to get result: %v", err)
+	} else {
+		keys = records.Keys()
+		sort.Strings(keys)
+	}
+
+	var (
+		count     int
+		documents = make([]KeyValueStoreDocument, 0)
+	)
+
+	for _, key := range keys {
+		data, ok := records.Get(key)
+		if !ok {
+			// deleted meanwhile
+			continue
 		}
-		if v == true {
-			// found it
-			result := KeyValueStoreDocument{}
-			if err = json.Unmarshal(data, &result); err != nil {
-				return fmt.Errorf("unable to decode result: %v", err)
+		if in.Query != "" {
+			matches, err := matchQuery(data, in.Query)
+			if err != nil {
+				return err
 			}
-			return output(ctx, PortQueryResult, KeyValueQueryResult{
-				Query:    in.Query,
-				Context:  in.Context,
-				Document: result,
-				Found:    true,
-			})
+			if !matches {
+				continue
+			}
+		}
+		count++
+		if in.CountOnly {
+			continue
 		}
+		doc := KeyValueStoreDocument{}
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("unable to decode result: %v", err)
+		}
+		documents = append(documents, doc)
 	}
 
-	return output(ctx, PortQueryResult, KeyValueQueryResult{
-		Query:   in.Query,
-		Context: in.Context,
-		Found:   false,
+	if in.SortBy != "" {
+		sortDocuments(documents, in.SortBy, in.SortDesc)
+	} else if in.SortDesc {
+		for i, j := 0, len(documents)-1; i < j; i, j = i+1, j-1 {
+			documents[i], documents[j] = documents[j], documents[i]
+		}
+	}
+	documents = documents[min(max(in.Offset, 0), len(documents)):]
+	if in.Limit > 0 && len(documents) > in.Limit {
+		documents = documents[:in.Limit]
+	}
+
+	result := KeyValueQueryResult{
+		Query:     in.Query,
+		Context:   in.Context,
+		Documents: documents,
+		Count:     count,
+		Found:     count > 0,
+	}
+	if len(documents) > 0 {
+		result.Document = documents[0]
+	}
+	return output(ctx, PortQueryResult, result)
+}
+
+// matchQuery evaluates JSONPath expression against stored document
+func matchQuery(data []byte, query string) (bool, error) {
+	node, err := ajson.Unmarshal(data)
+	if err != nil {
+		return false, fmt.Errorf("unable to encode stored message")
+	}
+	jsonPathResult, err := ajson.Eval(node, query)
+	if err != nil {
+		return false, fmt.Errorf("unable to eval query: %v", err)
+	}
+	v, err := jsonPathResult.Unpack()
+	if err != nil {
+		return false, fmt.Errorf("unable to get result: %v", err)
+	}
+	return v == true, nil
+}
+
+// sortDocuments sorts by field value in index order, documents without the field go last
+func sortDocuments(documents []KeyValueStoreDocument, field string, desc bool) {
+	sort.SliceStable(documents, func(i, j int) bool {
+		a, aok := documentIndexValue(documents[i], field)
+		b, bok := documentIndexValue(documents[j], field)
+		if !aok || !bok {
+			return aok && !bok
+		}
+		if desc {
+			return compareIndexValues(a, b) > 0
+		}
+		return compareIndexValues(a, b) < 0
 	})
 }
 
+func documentIndexValue(doc KeyValueStoreDocument, field string) (indexValue, bool) {
+	v, ok := fieldValue(doc, field)
+	if !ok {
+		return indexValue{}, false
+	}
+	return toIndexValue(v)
+}
+
+// configure applies settings, storage is reopened and records are restored from it when storage settings change
+func (k *KeyValueStore) configure(settings KeyValueStoreSettings) error {
+	if settings.Storage == "" {
+		settings.Storage = StorageMemory
+	}
+
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	if settings.Storage != k.settings.Storage || settings.Path != k.settings.Path {
+		storage, err := newStorage(settings)
+		if err != nil {
+			return err
+		}
+
+		records := cmap.New[[]byte]()
+		if err = storage.load(func(key string, value []byte) error {
+			records.Set(key, value)
+			return nil
+		}); err != nil {
+			_ = storage.close()
+			return fmt.Errorf("unable to restore records: %v", err)
+		}
+
+		if settings.Storage != k.settings.Storage {
+			// records are carried over between memory and disk, opening another file only restores its records
+			current := k.records.Items()
+			if err = storage.putAll(current); err != nil {
+				_ = storage.close()
+				return fmt.Errorf("unable to carry records over: %v", err)
+			}
+			records.MSet(current)
+		}
+
+		// previous storage stays in use if the new one fails to open
+		_ = k.storage.close()
+		k.storage = storage
+		k.records = records
+	}
+
+	if err := k.indexes.rebuild(indexFields(settings), k.records.Items()); err != nil {
+		return err
+	}
+	k.settings = settings
+	return nil
+}
+
+// snapshot returns settings and records, both are replaced when settings change
+func (k *KeyValueStore) snapshot() (KeyValueStoreSettings, cmap.ConcurrentMap[string, []byte]) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	return k.settings, k.records
+}
+
+// indexFields are the primary key and declared indexes
+func indexFields(settings KeyValueStoreSettings) []string {
+	fields := []string{settings.PrimaryKey}
+	for _, idx := range settings.Indexes {
+		if idx.Field != "" && idx.Field != settings.PrimaryKey {
+			fields = append(fields, idx.Field)
+		}
+	}
+	return fields
+}
+
+// write persists operation before applying it to records and indexes in memory
+func (k *KeyValueStore) write(operation string, key string, data []byte) error {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	var prev map[string]any
+	if prevData, ok := k.records.Get(key); ok {
+		prev = make(map[string]any)
+		if err := json.Unmarshal(prevData, &prev); err != nil {
+			return fmt.Errorf("unable to decode stored record: %v", err)
+		}
+	}
+
+	switch operation {
+	case OpStore:
+		next := make(map[string]any)
+		if err := json.Unmarshal(data, &next); err != nil {
+			return fmt.Errorf("unable to decode record: %v", err)
+		}
+		if err := k.storage.put(key, data); err != nil {
+			return fmt.Errorf("unable to store record: %v", err)
+		}
+		k.records.Set(key, data)
+		k.indexes.update(key, prev, next)
+	case OptDelete:
+		if err := k.storage.delete(key); err != nil {
+			return fmt.Errorf("unable to delete record: %v", err)
+		}
+		k.records.Remove(key)
+		k.indexes.update(key, prev, nil)
+	default:
+		return fmt.Errorf("unknown operation: %s", operation)
+	}
+	return nil
+}
+
 func (k *KeyValueStore) Ports() []module.Port {
 	ports := []module.Port{
 		{
@@ -219,10 +419,12 @@ func (k *KeyValueStore) Ports() []module.Port {
 				Document: KeyValueStoreDocument{
 					"id": "ID",
 				},
+				Storage: StorageMemory,
+				Indexes: make([]KeyValueIndex, 0),
 			},
 		},
 	}
-	if k.settings.EnableStoreAckPort {
+	if settings, _ := k.snapshot(); settings.EnableStoreAckPort {
 		ports = append(ports, module.Port{
 			Name:          PortStoreAck,
 			Label:         "Store ack",
@@ -236,13 +438,16 @@ func (k *KeyValueStore) Ports() []module.Port {
 
 func (k *KeyValueStore) Instance() module.Component {
 	return &KeyValueStore{
-		settings: KeyValueStoreSettings{}, // default settings
+		settings: KeyValueStoreSettings{Storage: StorageMemory}, // default settings
 		records:  cmap.New[[]byte](),
+		storage:  memoryStorage{},
+		indexes:  newIndexes(),
+		lock:     &sync.Mutex{},
 	}
 }
 
 var _ module.Component = (*KeyValueStore)(nil)
 
 func init() {
-	//registry.Register(&KeyValueStore{})
+	registry.Register(&KeyValueStore{})
 }
@@ -0,0 +1,332 @@
+package db
+
+import (
+	"context"
+	"github.com/tiny-systems/module/module"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestKeyValueStore_storage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kv", "store.db")
+
+	tests := []struct {
+		name      string
+		storage   string
+		path      string
+		wantFound bool
+	}{
+		{name: "memory", storage: StorageMemory, wantFound: false},
+		{name: "disk", storage: StorageBolt, path: path, wantFound: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := KeyValueStoreSettings{
+				Document:   KeyValueStoreDocument{"id": "", "name": ""},
+				PrimaryKey: "id",
+				Storage:    tt.storage,
+				Path:       tt.path,
+				Indexes:    []KeyValueIndex{{Field: "name"}},
+			}
+			noop := func(ctx context.Context, port string, data any) error {
+				return nil
+			}
+
+			store := (&KeyValueStore{}).Instance().(*KeyValueStore)
+			if err := store.Handle(context.Background(), noop, module.SettingsPort, settings); err != nil {
+				t.Fatal(err)
+			}
+			for _, id := range []string{"1", "2"} {
+				if err := store.Handle(context.Background(), noop, PortStore, KeyValueStoreRequest{
+					Operation: OpStore,
+					Document:  KeyValueStoreDocument{"id": id, "name": "doc" + id},
+				}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := store.Handle(context.Background(), noop, PortStore, KeyValueStoreRequest{
+				Operation: OptDelete,
+				Document:  KeyValueStoreDocument{"id": "2"},
+			}); err != nil {
+				t.Fatal(err)
+			}
+			// settings sent again keep storage open
+			if err := store.Handle(context.Background(), noop, module.SettingsPort, settings); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.storage.close(); err != nil {
+				t.Fatal(err)
+			}
+
+			// restart
+			restored := (&KeyValueStore{}).Instance().(*KeyValueStore)
+			if err := restored.Handle(context.Background(), noop, module.SettingsPort, settings); err != nil {
+				t.Fatal(err)
+			}
+			defer restored.storage.close()
+
+			results := make(map[string]KeyValueQueryResult)
+			handler := func(ctx context.Context, port string, data any) error {
+				result := data.(KeyValueQueryResult)
+				results[result.Query] = result
+				return nil
+			}
+			for _, query := range []string{`$.name == "doc1"`, `$.name == "doc2"`} {
+				if err := restored.Handle(context.Background(), handler, PortQuery, KeyValueQueryRequest{Query: query}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if results[`$.name == "doc1"`].Found != tt.wantFound {
+				t.Errorf("stored record found = %v, want %v", results[`$.name == "doc1"`].Found, tt.wantFound)
+			}
+			if results[`$.name == "doc2"`].Found {
+				t.Errorf("deleted record should not be restored")
+			}
+
+			// indexes are rebuilt from restored records
+			if err := restored.Handle(context.Background(), handler, PortQuery, KeyValueQueryRequest{Index: "name", Equals: "doc1"}); err != nil {
+				t.Fatal(err)
+			}
+			if results[""].Found != tt.wantFound {
+				t.Errorf("index lookup found = %v, want %v", results[""].Found, tt.wantFound)
+			}
+		})
+	}
+}
+
+func TestKeyValueStore_settings(t *testing.T) {
+	store := (&KeyValueStore{}).Instance().(*KeyValueStore)
+	noop := func(ctx context.Context, port string, data any) error {
+		return nil
+	}
+	if err := store.Handle(context.Background(), noop, module.SettingsPort, KeyValueStoreSettings{
+		Document:   KeyValueStoreDocument{"id": ""},
+		PrimaryKey: "id",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		storage string
+		path    string
+	}{
+		{name: "disk without path", storage: StorageBolt},
+		{name: "unknown storage", storage: "redis"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := store.Handle(context.Background(), noop, module.SettingsPort, KeyValueStoreSettings{
+				Document:   KeyValueStoreDocument{"id": ""},
+				PrimaryKey: "id",
+				Storage:    tt.storage,
+				Path:       tt.path,
+			})
+			if err == nil {
+				t.Errorf("settings should be rejected")
+			}
+		})
+	}
+
+	// previous storage is kept
+	if err := store.Handle(context.Background(), noop, PortStore, KeyValueStoreRequest{
+		Operation: OpStore,
+		Document:  KeyValueStoreDocument{"id": "1"},
+	}); err != nil {
+		t.Errorf("store after rejected settings error = %v", err)
+	}
+}
+
+func TestKeyValueStore_query(t *testing.T) {
+	store := (&KeyValueStore{}).Instance().(*KeyValueStore)
+	noop := func(ctx context.Context, port string, data any) error {
+		return nil
+	}
+	if err := store.Handle(context.Background(), noop, module.SettingsPort, KeyValueStoreSettings{
+		Document:   KeyValueStoreDocument{"id": "", "age": 0, "city": ""},
+		PrimaryKey: "id",
+		Indexes:    []KeyValueIndex{{Field: "age"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for _, doc := range []KeyValueStoreDocument{
+		{"id": "u1", "age": 30, "city": "Oslo"},
+		{"id": "u2", "age": 20, "city": "Rome"},
+		{"id": "u3", "age": 40, "city": "Oslo"},
+		{"id": "u4", "age": 30, "city": "Rome"},
+		{"id": "u5", "age": nil, "city": "Bergen"},
+		{"id": "u6", "age": "unknown", "city": "Rome"},
+	} {
+		if err := store.Handle(context.Background(), noop, PortStore, KeyValueStoreRequest{Operation: OpStore, Document: doc}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// updated record moves in the index
+	if err := store.Handle(context.Background(), noop, PortStore, KeyValueStoreRequest{Operation: OpStore, Document: KeyValueStoreDocument{"id": "u2", "age": 50, "city": "Rome"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		query     KeyValueQueryRequest
+		wantIDs   []string
+		wantCount int
+		wantErr   bool
+	}{
+		{name: "scan", query: KeyValueQueryRequest{Query: `$.city == "Oslo"`}, wantIDs: []string{"u1", "u3"}, wantCount: 2},
+		{name: "primary key", query: KeyValueQueryRequest{Index: "id", Equals: "u3"}, wantIDs: []string{"u3"}, wantCount: 1},
+		{name: "primary key range", query: KeyValueQueryRequest{Index: "id", From: "u2", To: "u3"}, wantIDs: []string{"u2", "u3"}, wantCount: 2},
+		{name: "exact", query: KeyValueQueryRequest{Index: "age", Equals: 30}, wantIDs: []string{"u1", "u4"}, wantCount: 2},
+		{name: "old value", query: KeyValueQueryRequest{Index: "age", Equals: 20}, wantIDs: []string{}, wantCount: 0},
+		{name: "range", query: KeyValueQueryRequest{Index: "age", From: 35}, wantIDs: []string{"u3", "u2"}, wantCount: 2},
+		{name: "range with filter", query: KeyValueQueryRequest{Index: "age", From: 30, To: 45, Query: `$.city == "Rome"`}, wantIDs: []string{"u4"}, wantCount: 1},
+		{name: "descending", query: KeyValueQueryRequest{Index: "age", From: 0, SortDesc: true, Limit: 2}, wantIDs: []string{"u2", "u3"}, wantCount: 4},
+		{name: "sort by field", query: KeyValueQueryRequest{Query: `$.age > 0`, SortBy: "city", Offset: 1, Limit: 2}, wantIDs: []string{"u3", "u2"}, wantCount: 4},
+		{name: "offset past end", query: KeyValueQueryRequest{Index: "age", From: 0, Offset: 10}, wantIDs: []string{}, wantCount: 4},
+		{name: "count only", query: KeyValueQueryRequest{Index: "age", From: 30, CountOnly: true}, wantIDs: []string{}, wantCount: 4},
+		{name: "open upper bound stays within numbers", query: KeyValueQueryRequest{Index: "age", From: 45}, wantIDs: []string{"u2"}, wantCount: 1},
+		{name: "open lower bound stays within numbers", query: KeyValueQueryRequest{Index: "age", To: 30}, wantIDs: []string{"u1", "u4"}, wantCount: 2},
+		{name: "exact null", query: KeyValueQueryRequest{Index: "age", Lookup: LookupEquals}, wantIDs: []string{"u5"}, wantCount: 1},
+		{name: "explicit range", query: KeyValueQueryRequest{Index: "age", Lookup: LookupRange, Equals: 30, From: "a"}, wantIDs: []string{"u6"}, wantCount: 1},
+		{name: "bounds of different types", query: KeyValueQueryRequest{Index: "age", From: 10, To: "z"}, wantErr: true},
+		{name: "not indexed", query: KeyValueQueryRequest{Index: "city", Equals: "Oslo"}, wantErr: true},
+		{name: "empty", query: KeyValueQueryRequest{}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var result KeyValueQueryResult
+			handler := func(ctx context.Context, port string, data any) error {
+				result = data.(KeyValueQueryResult)
+				return nil
+			}
+			err := store.Handle(context.Background(), handler, PortQuery, tt.query)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("query error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			ids := make([]string, 0, len(result.Documents))
+			for _, doc := range result.Documents {
+				ids = append(ids, doc["id"].(string))
+			}
+			if !reflect.DeepEqual(ids, tt.wantIDs) || result.Count != tt.wantCount || result.Found != (tt.wantCount > 0) {
+				t.Errorf("query result = %v, count %d, want %v, count %d", ids, result.Count, tt.wantIDs, tt.wantCount)
+			}
+			if len(ids) > 0 && result.Document["id"] != ids[0] {
+				t.Errorf("document should be the first of documents")
+			}
+		})
+	}
+}
+
+func TestKeyValueStore_switchStorage(t *testing.T) {
+	noop := func(ctx context.Context, port string, data any) error {
+		return nil
+	}
+	memory := KeyValueStoreSettings{
+		Document:   KeyValueStoreDocument{"id": "", "name": ""},
+		PrimaryKey: "id",
+		Storage:    StorageMemory,
+	}
+	disk := memory
+	disk.Storage = StorageBolt
+	disk.Path = filepath.Join(t.TempDir(), "store.db")
+
+	var found bool
+	handler := func(ctx context.Context, port string, data any) error {
+		found = data.(KeyValueQueryResult).Found
+		return nil
+	}
+	lookup := func(store *KeyValueStore, id string) bool {
+		t.Helper()
+		found = false
+		if err := store.Handle(context.Background(), handler, PortQuery, KeyValueQueryRequest{Index: "id", Equals: id}); err != nil {
+			t.Fatal(err)
+		}
+		return found
+	}
+
+	store := (&KeyValueStore{}).Instance().(*KeyValueStore)
+	if err := store.Handle(context.Background(), noop, module.SettingsPort, memory); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Handle(context.Background(), noop, PortStore, KeyValueStoreRequest{
+		Operation: OpStore,
+		Document:  KeyValueStoreDocument{"id": "1", "name": "kept"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Handle(context.Background(), noop, module.SettingsPort, disk); err != nil {
+		t.Fatal(err)
+	}
+	if !lookup(store, "1") {
+		t.Errorf("record kept in memory should be carried over to disk")
+	}
+	if err := store.Handle(context.Background(), noop, module.SettingsPort, memory); err != nil {
+		t.Fatal(err)
+	}
+	if !lookup(store, "1") {
+		t.Errorf("record should be carried over back to memory")
+	}
+
+	// restart
+	restored := (&KeyValueStore{}).Instance().(*KeyValueStore)
+	if err := restored.Handle(context.Background(), noop, module.SettingsPort, disk); err != nil {
+		t.Fatal(err)
+	}
+	defer restored.storage.close()
+	if !lookup(restored, "1") {
+		t.Errorf("carried over record should be persisted")
+	}
+}
+
+func TestKeyValueStore_concurrentSettings(t *testing.T) {
+	noop := func(ctx context.Context, port string, data any) error {
+		return nil
+	}
+	settings := KeyValueStoreSettings{
+		Document:   KeyValueStoreDocument{"id": ""},
+		PrimaryKey: "id",
+		Storage:    StorageMemory,
+	}
+	store := (&KeyValueStore{}).Instance().(*KeyValueStore)
+	if err := store.Handle(context.Background(), noop, module.SettingsPort, settings); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		disk := settings
+		disk.Storage = StorageBolt
+		for i := 0; i < 20; i++ {
+			next := settings
+			if i%2 == 0 {
+				next = disk
+				next.Path = filepath.Join(t.TempDir(), "store.db")
+			}
+			if err := store.Handle(context.Background(), noop, module.SettingsPort, next); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+
+	// run with -race, settings swap records and settings read by store and query
+	for i := 0; i < 200; i++ {
+		if err := store.Handle(context.Background(), noop, PortStore, KeyValueStoreRequest{
+			Operation: OpStore,
+			Document:  KeyValueStoreDocument{"id": "1"},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Handle(context.Background(), noop, PortQuery, KeyValueQueryRequest{Query: "$.id == \"1\""}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-done
+	_ = store.storage.close()
+}
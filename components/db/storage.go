@@ -0,0 +1,129 @@
+package db
+
+import (
+	"fmt"
+	bolt "go.etcd.io/bbolt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	StorageMemory = "memory"
+	StorageBolt   = "bolt"
+)
+
+// boltOpenTimeout limits waiting for the file lock held by another process
+const boltOpenTimeout = 5 * time.Second
+
+var boltBucket = []byte("records")
+
+// kvStorage persists records, the store keeps working copy in memory restored from the storage on start
+type kvStorage interface {
+	// load calls fn for every persisted record
+	load(fn func(key string, value []byte) error) error
+	put(key string, value []byte) error
+	// putAll stores records at once, used to carry records over from another storage
+	putAll(records map[string][]byte) error
+	delete(key string) error
+	close() error
+}
+
+func newStorage(settings KeyValueStoreSettings) (kvStorage, error) {
+	switch settings.Storage {
+	case "", StorageMemory:
+		return memoryStorage{}, nil
+	case StorageBolt:
+		return openBoltStorage(settings.Path)
+	default:
+		return nil, fmt.Errorf("unknown storage %s", settings.Storage)
+	}
+}
+
+// memoryStorage persists nothing, records are lost on restart
+type memoryStorage struct{}
+
+func (memoryStorage) load(func(key string, value []byte) error) error {
+	return nil
+}
+
+func (memoryStorage) put(string, []byte) error {
+	return nil
+}
+
+func (memoryStorage) putAll(map[string][]byte) error {
+	return nil
+}
+
+func (memoryStorage) delete(string) error {
+	return nil
+}
+
+func (memoryStorage) close() error {
+	return nil
+}
+
+// boltStorage keeps records in embedded bbolt database file
+type boltStorage struct {
+	db *bolt.DB
+}
+
+func openBoltStorage(path string) (*boltStorage, error) {
+	if path == "" {
+		return nil, fmt.Errorf("storage file path can not be empty")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("unable to create storage directory: %v", err)
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: boltOpenTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("unable to open storage file: %v", err)
+	}
+	if err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltBucket)
+		return err
+	}); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &boltStorage{db: db}, nil
+}
+
+func (s *boltStorage) load(fn func(key string, value []byte) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).ForEach(func(k, v []byte) error {
+			// values are only valid during transaction
+			value := make([]byte, len(v))
+			copy(value, v)
+			return fn(string(k), value)
+		})
+	})
+}
+
+func (s *boltStorage) put(key string, value []byte) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Put([]byte(key), value)
+	})
+}
+
+func (s *boltStorage) putAll(records map[string][]byte) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucket)
+		for key, value := range records {
+			if err := bucket.Put([]byte(key), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *boltStorage) delete(key string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Delete([]byte(key))
+	})
+}
+
+func (s *boltStorage) close() error {
+	return s.db.Close()
+}
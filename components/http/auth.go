@@ -0,0 +1,206 @@
+package http
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	AuthMethodAPIKey = "apiKey"
+	AuthMethodBasic  = "basic"
+	AuthMethodHMAC   = "hmac"
+)
+
+// defaultHMACMaxBody limits signed bodies when max size is not configured
+const defaultHMACMaxBody = 1 << 20
+
+const (
+	HMACAlgorithmSHA1   = "sha1"
+	HMACAlgorithmSHA256 = "sha256"
+	HMACAlgorithmSHA512 = "sha512"
+)
+
+type ServerAPIKey struct {
+	Name string `json:"name" required:"true" title:"Name" description:"Principal name" colSpan:"col-span-6"`
+	Key  string `json:"key" required:"true" title:"Key" minLength:"1" colSpan:"col-span-6"`
+}
+
+type ServerBasicUser struct {
+	Username string `json:"username" required:"true" title:"Username" minLength:"1" colSpan:"col-span-6"`
+	Password string `json:"password" required:"true" title:"Password" colSpan:"col-span-6"`
+}
+
+type ServerAuth struct {
+	EnableAPIKey bool           `json:"enableAPIKey" title:"Enable API keys" description:"Accept static API keys passed in a header or as a bearer token"`
+	APIKeyHeader string         `json:"apiKeyHeader" title:"API key header" default:"X-API-Key"`
+	APIKeys      []ServerAPIKey `json:"apiKeys" title:"API keys"`
+
+	EnableBasic bool              `json:"enableBasic" title:"Enable basic auth" description:"Accept HTTP basic auth"`
+	BasicRealm  string            `json:"basicRealm" title:"Basic auth realm" default:"Restricted"`
+	BasicUsers  []ServerBasicUser `json:"basicUsers" title:"Basic auth users"`
+
+	EnableHMAC    bool   `json:"enableHMAC" title:"Enable HMAC signatures" description:"Accept webhooks with request body signed by shared secret"`
+	HMACHeader    string `json:"hmacHeader" title:"Signature header" default:"X-Signature"`
+	HMACSecret    string `json:"hmacSecret" title:"Signature secret"`
+	HMACAlgorithm string `json:"hmacAlgorithm" title:"Signature algorithm" enum:"sha1,sha256,sha512" enumTitles:"SHA1,SHA256,SHA512" default:"sha256"`
+	HMACPrefix    string `json:"hmacPrefix" title:"Signature prefix" description:"Prefix to strip from the header value, e.g. sha256="`
+	HMACMaxBody   int64  `json:"hmacMaxBody" title:"Max signed body size" description:"Maximum size of request body read to verify its signature, bytes. Larger requests are rejected with 413" minimum:"0" default:"1048576"`
+}
+
+type ServerPrincipal struct {
+	Method string `json:"method" title:"Auth method"`
+	Name   string `json:"name" title:"Name"`
+}
+
+func (a ServerAuth) isEnabled() bool {
+	return a.EnableAPIKey || a.EnableBasic || a.EnableHMAC
+}
+
+func (a ServerAuth) validate() error {
+	if a.EnableHMAC && a.HMACSecret == "" {
+		return fmt.Errorf("signature secret can not be empty")
+	}
+	if _, err := hmacHash(a.HMACAlgorithm); a.EnableHMAC && err != nil {
+		return err
+	}
+	return nil
+}
+
+// authenticate checks request against enabled auth methods, nil principal means request is not authenticated
+func (a ServerAuth) authenticate(req *http.Request) (*ServerPrincipal, error) {
+	if a.EnableAPIKey {
+		if p := a.authenticateAPIKey(req); p != nil {
+			return p, nil
+		}
+	}
+	if a.EnableBasic {
+		if p := a.authenticateBasic(req); p != nil {
+			return p, nil
+		}
+	}
+	if a.EnableHMAC {
+		return a.authenticateHMAC(req)
+	}
+	return nil, nil
+}
+
+func (a ServerAuth) authenticateAPIKey(req *http.Request) *ServerPrincipal {
+	header := a.APIKeyHeader
+	if header == "" {
+		header = "X-API-Key"
+	}
+
+	key := req.Header.Get(header)
+	if key == "" {
+		if bearer, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer "); ok {
+			key = strings.TrimSpace(bearer)
+		}
+	}
+	if key == "" {
+		return nil
+	}
+
+	for _, k := range a.APIKeys {
+		if k.Key != "" && secureCompare(k.Key, key) {
+			return &ServerPrincipal{Method: AuthMethodAPIKey, Name: k.Name}
+		}
+	}
+	return nil
+}
+
+func (a ServerAuth) authenticateBasic(req *http.Request) *ServerPrincipal {
+	username, password, ok := req.BasicAuth()
+	if !ok {
+		return nil
+	}
+	for _, u := range a.BasicUsers {
+		// compare both to not leak which one is wrong via timing
+		userOk := secureCompare(u.Username, username)
+		passOk := secureCompare(u.Password, password)
+		if userOk && passOk {
+			return &ServerPrincipal{Method: AuthMethodBasic, Name: u.Username}
+		}
+	}
+	return nil
+}
+
+// authenticateHMAC reads the whole body to verify its signature and puts it back for further parsing
+func (a ServerAuth) authenticateHMAC(req *http.Request) (*ServerPrincipal, error) {
+	header := a.HMACHeader
+	if header == "" {
+		header = "X-Signature"
+	}
+
+	signature := strings.TrimPrefix(req.Header.Get(header), a.HMACPrefix)
+	if signature == "" {
+		return nil, nil
+	}
+
+	newHash, err := hmacHash(a.HMACAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	maxBody := a.HMACMaxBody
+	if maxBody <= 0 {
+		maxBody = defaultHMACMaxBody
+	}
+
+	// body is read before any other check, do not let unauthenticated clients buffer unlimited amount of data
+	body, err := io.ReadAll(http.MaxBytesReader(nil, req.Body, maxBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	mac := hmac.New(newHash, []byte(a.HMACSecret))
+	mac.Write(body)
+	expected := mac.Sum(nil)
+
+	if actual, err := hex.DecodeString(signature); err == nil && hmac.Equal(expected, actual) {
+		return &ServerPrincipal{Method: AuthMethodHMAC, Name: header}, nil
+	}
+	if actual, err := base64.StdEncoding.DecodeString(signature); err == nil && hmac.Equal(expected, actual) {
+		return &ServerPrincipal{Method: AuthMethodHMAC, Name: header}, nil
+	}
+	return nil, nil
+}
+
+// challenge header for unauthenticated responses
+func (a ServerAuth) challenge() string {
+	if !a.EnableBasic {
+		return ""
+	}
+	realm := a.BasicRealm
+	if realm == "" {
+		realm = "Restricted"
+	}
+	return fmt.Sprintf("Basic realm=%q", realm)
+}
+
+func hmacHash(algorithm string) (func() hash.Hash, error) {
+	switch algorithm {
+	case HMACAlgorithmSHA1:
+		return sha1.New, nil
+	case HMACAlgorithmSHA256, "":
+		return sha256.New, nil
+	case HMACAlgorithmSHA512:
+		return sha512.New, nil
+	}
+	return nil, fmt.Errorf("unknown signature algorithm: %s", algorithm)
+}
+
+func secureCompare(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
@@ -0,0 +1,127 @@
+package http
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServerAuth_authenticate(t *testing.T) {
+	auth := ServerAuth{
+		EnableAPIKey: true,
+		APIKeyHeader: "X-API-Key",
+		APIKeys:      []ServerAPIKey{{Name: "service", Key: "secret-key"}},
+		EnableBasic:  true,
+		BasicUsers:   []ServerBasicUser{{Username: "admin", Password: "pass"}},
+		EnableHMAC:   true,
+		HMACHeader:   "X-Hub-Signature-256",
+		HMACSecret:   "webhook",
+		HMACPrefix:   "sha256=",
+	}
+
+	body := `{"event":"push"}`
+	mac := hmac.New(sha256.New, []byte("webhook"))
+	mac.Write([]byte(body))
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	tests := []struct {
+		name       string
+		headers    map[string]string
+		basic      []string
+		wantMethod string
+		wantName   string
+	}{
+		{
+			name:       "api key header",
+			headers:    map[string]string{"X-API-Key": "secret-key"},
+			wantMethod: AuthMethodAPIKey,
+			wantName:   "service",
+		},
+		{
+			name:       "bearer token",
+			headers:    map[string]string{"Authorization": "Bearer secret-key"},
+			wantMethod: AuthMethodAPIKey,
+			wantName:   "service",
+		},
+		{
+			name:    "wrong api key",
+			headers: map[string]string{"X-API-Key": "other"},
+		},
+		{
+			name:       "basic auth",
+			basic:      []string{"admin", "pass"},
+			wantMethod: AuthMethodBasic,
+			wantName:   "admin",
+		},
+		{
+			name:  "wrong password",
+			basic: []string{"admin", "wrong"},
+		},
+		{
+			name:       "hmac signature",
+			headers:    map[string]string{"X-Hub-Signature-256": signature},
+			wantMethod: AuthMethodHMAC,
+			wantName:   "X-Hub-Signature-256",
+		},
+		{
+			name:    "invalid hmac signature",
+			headers: map[string]string{"X-Hub-Signature-256": "sha256=00"},
+		},
+		{
+			name: "no credentials",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			if len(tt.basic) == 2 {
+				req.SetBasicAuth(tt.basic[0], tt.basic[1])
+			}
+
+			principal, err := auth.authenticate(req)
+			if err != nil {
+				t.Fatalf("authenticate() error = %v", err)
+			}
+			if tt.wantMethod == "" {
+				if principal != nil {
+					t.Errorf("authenticate() principal = %v, want nil", principal)
+				}
+				return
+			}
+			if principal == nil || principal.Method != tt.wantMethod || principal.Name != tt.wantName {
+				t.Errorf("authenticate() principal = %v, want %s %s", principal, tt.wantMethod, tt.wantName)
+			}
+
+			// body should stay readable for request parsing
+			if b, _ := io.ReadAll(req.Body); string(b) != body {
+				t.Errorf("request body = %q, want %q", b, body)
+			}
+		})
+	}
+}
+
+func TestServerAuth_authenticateHMACMaxBody(t *testing.T) {
+	auth := ServerAuth{
+		EnableHMAC:  true,
+		HMACSecret:  "webhook",
+		HMACMaxBody: 16,
+	}
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader(strings.Repeat("x", 17)))
+	req.Header.Set("X-Signature", "00")
+
+	_, err := auth.authenticate(req)
+	var maxBytesErr *http.MaxBytesError
+	if !errors.As(err, &maxBytesErr) {
+		t.Errorf("authenticate() error = %v, want max bytes error", err)
+	}
+}
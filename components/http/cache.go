@@ -0,0 +1,202 @@
+package http
+
+import (
+	"bytes"
+	"container/list"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const HeaderXCache = "X-Cache"
+
+type ServerCache struct {
+	Enable              bool     `json:"enable" title:"Enable cache" description:"Successful GET responses are served from cache without sending a request message"`
+	TTL                 int      `json:"ttl" title:"TTL" description:"Seconds responses are cached for unless response Cache-Control max-age says otherwise" minimum:"1" default:"60"`
+	MaxEntries          int      `json:"maxEntries" title:"Max entries" description:"Least recently used responses are evicted above this number" minimum:"1" default:"1000"`
+	VaryHeaders         []string `json:"varyHeaders" title:"Key headers" description:"Request headers which are part of the cache key besides method, path and query"`
+	RespectCacheControl bool     `json:"respectCacheControl" title:"Respect Cache-Control" description:"Honour no-cache, no-store and max-age directives of requests and responses"`
+}
+
+type cacheEntry struct {
+	key        string
+	statusCode int
+	header     http.Header
+	body       []byte
+	stored     time.Time
+	expires    time.Time
+}
+
+// responseCache is LRU cache of responses
+type responseCache struct {
+	lock    *sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+func newResponseCache() *responseCache {
+	return &responseCache{
+		lock:    &sync.Mutex{},
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+func (r *responseCache) get(key string) (*cacheEntry, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	el, ok := r.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*cacheEntry)
+	if time.Now().After(entry.expires) {
+		r.lru.Remove(el)
+		delete(r.entries, key)
+		return nil, false
+	}
+	r.lru.MoveToFront(el)
+	return entry, true
+}
+
+func (r *responseCache) put(entry *cacheEntry, maxEntries int) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	// cookies belong to the client the response was made for
+	entry.header.Del("Set-Cookie")
+
+	if el, ok := r.entries[entry.key]; ok {
+		el.Value = entry
+		r.lru.MoveToFront(el)
+	} else {
+		r.entries[entry.key] = r.lru.PushFront(entry)
+	}
+
+	for maxEntries > 0 && r.lru.Len() > maxEntries {
+		oldest := r.lru.Back()
+		r.lru.Remove(oldest)
+		delete(r.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// cacheKey identifies response, authenticated principals never share cached responses
+func cacheKey(settings ServerCache, req *http.Request, principal *ServerPrincipal) string {
+	b := strings.Builder{}
+	if principal != nil {
+		b.WriteString(principal.Method)
+		b.WriteString(":")
+		b.WriteString(principal.Name)
+		b.WriteString(" ")
+	}
+	b.WriteString(req.Method)
+	b.WriteString(" ")
+	b.WriteString(req.URL.Path)
+	b.WriteString("?")
+	// Encode sorts query by key
+	b.WriteString(sortedQuery(req.URL.Query()))
+
+	headers := append([]string{}, settings.VaryHeaders...)
+	sort.Strings(headers)
+	for _, h := range headers {
+		b.WriteString("\n")
+		b.WriteString(http.CanonicalHeaderKey(h))
+		b.WriteString(":")
+		b.WriteString(strings.Join(req.Header.Values(h), ","))
+	}
+	return b.String()
+}
+
+func sortedQuery(q url.Values) string {
+	for _, v := range q {
+		sort.Strings(v)
+	}
+	return q.Encode()
+}
+
+// cacheControl parses Cache-Control header directives
+func cacheControl(header string) map[string]string {
+	directives := make(map[string]string)
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		k, v, _ := strings.Cut(part, "=")
+		directives[strings.ToLower(strings.TrimSpace(k))] = strings.Trim(strings.TrimSpace(v), `"`)
+	}
+	return directives
+}
+
+// requestBypassesCache tells if request asks not to be served from cache
+func requestBypassesCache(settings ServerCache, req *http.Request) bool {
+	if !settings.RespectCacheControl {
+		return false
+	}
+	directives := cacheControl(req.Header.Get("Cache-Control"))
+	_, noCache := directives["no-cache"]
+	_, noStore := directives["no-store"]
+	return noCache || noStore || req.Header.Get("Pragma") == "no-cache"
+}
+
+// responseTTL calculates how long response may be cached, zero means it should not be cached
+func responseTTL(settings ServerCache, req *http.Request, header http.Header, principal *ServerPrincipal) time.Duration {
+	directives := cacheControl(header.Get("Cache-Control"))
+
+	if _, public := directives["public"]; !public && principal == nil && req.Header.Get("Authorization") != "" {
+		// credentials the server does not know about, response may be personal
+		return 0
+	}
+
+	ttl := time.Duration(settings.TTL) * time.Second
+	if !settings.RespectCacheControl {
+		return ttl
+	}
+
+	if _, noStore := cacheControl(req.Header.Get("Cache-Control"))["no-store"]; noStore {
+		return 0
+	}
+
+	for _, d := range []string{"no-store", "no-cache", "private"} {
+		if _, ok := directives[d]; ok {
+			return 0
+		}
+	}
+	for _, d := range []string{"s-maxage", "max-age"} {
+		if v, ok := directives[d]; ok {
+			if seconds, err := strconv.Atoi(v); err == nil {
+				return time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	return ttl
+}
+
+// captureWriter copies response body so it can be cached
+type captureWriter struct {
+	http.ResponseWriter
+	body    *bytes.Buffer
+	flushed bool
+}
+
+func (w *captureWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush streamed responses are never cached
+func (w *captureWriter) Flush() {
+	w.flushed = true
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *captureWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
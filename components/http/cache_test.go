@@ -0,0 +1,164 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_responseCache_eviction(t *testing.T) {
+	cache := newResponseCache()
+	put := func(key string, expires time.Time) {
+		cache.put(&cacheEntry{key: key, header: http.Header{}, expires: expires}, 2)
+	}
+	future := time.Now().Add(time.Minute)
+
+	put("a", future)
+	put("b", future)
+	// a becomes most recently used
+	if _, ok := cache.get("a"); !ok {
+		t.Fatalf("a should be cached")
+	}
+	put("c", future)
+
+	if _, ok := cache.get("b"); ok {
+		t.Errorf("least recently used entry should be evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := cache.get(key); !ok {
+			t.Errorf("%s should be cached", key)
+		}
+	}
+
+	put("d", time.Now().Add(-time.Second))
+	if _, ok := cache.get("d"); ok {
+		t.Errorf("expired entry should not be returned")
+	}
+	if _, ok := cache.entries["d"]; ok {
+		t.Errorf("expired entry should be removed")
+	}
+}
+
+func Test_responseCache_setCookie(t *testing.T) {
+	cache := newResponseCache()
+	header := http.Header{}
+	header.Set("Set-Cookie", "session=secret")
+	header.Set(HeaderContentType, MimeTextPlain)
+
+	cache.put(&cacheEntry{key: "a", header: header, expires: time.Now().Add(time.Minute)}, 10)
+
+	entry, ok := cache.get("a")
+	if !ok {
+		t.Fatalf("entry should be cached")
+	}
+	if entry.header.Get("Set-Cookie") != "" {
+		t.Errorf("Set-Cookie should not be stored")
+	}
+	if entry.header.Get(HeaderContentType) != MimeTextPlain {
+		t.Errorf("other headers should be stored")
+	}
+}
+
+func Test_cacheKey(t *testing.T) {
+	settings := ServerCache{VaryHeaders: []string{"accept-language"}}
+
+	newReq := func(target string, lang string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if lang != "" {
+			req.Header.Set("Accept-Language", lang)
+		}
+		return req
+	}
+	alice := &ServerPrincipal{Method: AuthMethodBasic, Name: "alice"}
+	bob := &ServerPrincipal{Method: AuthMethodBasic, Name: "bob"}
+
+	if cacheKey(settings, newReq("/a?x=1&y=2", ""), nil) != cacheKey(settings, newReq("/a?y=2&x=1", ""), nil) {
+		t.Errorf("query param order should not change the key")
+	}
+	if cacheKey(settings, newReq("/a?x=1", "en"), nil) == cacheKey(settings, newReq("/a?x=1", "de"), nil) {
+		t.Errorf("vary headers should be part of the key")
+	}
+	if cacheKey(settings, newReq("/a", ""), alice) == cacheKey(settings, newReq("/a", ""), bob) {
+		t.Errorf("principals should not share the key")
+	}
+	if cacheKey(settings, newReq("/a", ""), alice) == cacheKey(settings, newReq("/a", ""), nil) {
+		t.Errorf("authenticated and anonymous requests should not share the key")
+	}
+}
+
+func Test_cacheControl(t *testing.T) {
+	got := cacheControl(`public, Max-Age=60, no-cache="Set-Cookie",,`)
+	want := map[string]string{"public": "", "max-age": "60", "no-cache": "Set-Cookie"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("cacheControl() = %v, want %v", got, want)
+	}
+}
+
+func Test_requestBypassesCache(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings ServerCache
+		headers  map[string]string
+		want     bool
+	}{
+		{name: "plain request", settings: ServerCache{RespectCacheControl: true}},
+		{name: "no-cache", settings: ServerCache{RespectCacheControl: true}, headers: map[string]string{"Cache-Control": "no-cache"}, want: true},
+		{name: "no-store", settings: ServerCache{RespectCacheControl: true}, headers: map[string]string{"Cache-Control": "max-age=0, no-store"}, want: true},
+		{name: "pragma", settings: ServerCache{RespectCacheControl: true}, headers: map[string]string{"Pragma": "no-cache"}, want: true},
+		{name: "ignored directives", settings: ServerCache{}, headers: map[string]string{"Cache-Control": "no-cache"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			if got := requestBypassesCache(tt.settings, req); got != tt.want {
+				t.Errorf("requestBypassesCache() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_responseTTL(t *testing.T) {
+	respect := ServerCache{TTL: 60, RespectCacheControl: true}
+	principal := &ServerPrincipal{Method: AuthMethodAPIKey, Name: "service"}
+
+	tests := []struct {
+		name           string
+		settings       ServerCache
+		requestHeaders map[string]string
+		cacheControl   string
+		principal      *ServerPrincipal
+		want           time.Duration
+	}{
+		{name: "default ttl", settings: respect, want: time.Minute},
+		{name: "max-age", settings: respect, cacheControl: "max-age=10", want: 10 * time.Second},
+		{name: "s-maxage wins", settings: respect, cacheControl: "max-age=10, s-maxage=20", want: 20 * time.Second},
+		{name: "invalid max-age", settings: respect, cacheControl: "max-age=soon", want: time.Minute},
+		{name: "no-store response", settings: respect, cacheControl: "no-store"},
+		{name: "private response", settings: respect, cacheControl: "private, max-age=10"},
+		{name: "no-store request", settings: respect, requestHeaders: map[string]string{"Cache-Control": "no-store"}},
+		{name: "directives ignored", settings: ServerCache{TTL: 60}, cacheControl: "no-store", want: time.Minute},
+		{name: "unknown credentials", settings: respect, requestHeaders: map[string]string{"Authorization": "Bearer x"}},
+		{name: "unknown credentials public response", settings: respect, requestHeaders: map[string]string{"Authorization": "Bearer x"}, cacheControl: "public", want: time.Minute},
+		{name: "authenticated principal", settings: respect, requestHeaders: map[string]string{"Authorization": "Bearer x"}, principal: principal, want: time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			for k, v := range tt.requestHeaders {
+				req.Header.Set(k, v)
+			}
+			header := http.Header{}
+			if tt.cacheControl != "" {
+				header.Set("Cache-Control", tt.cacheControl)
+			}
+			if got := responseTTL(tt.settings, req, header, tt.principal); got != tt.want {
+				t.Errorf("responseTTL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package http
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	CassetteOff    = "off"
+	CassetteRecord = "record"
+	CassetteReplay = "replay"
+)
+
+type ClientCassette struct {
+	Mode         string              `json:"mode" title:"Mode" enum:"off,record,replay" enumTitles:"Off,Record,Replay" default:"off" description:"Record sent requests with their responses, or replay recorded responses without sending requests"`
+	File         string              `json:"file" title:"File" description:"Local file the cassette is kept in. Interactions are kept in settings if empty"`
+	Interactions []ClientInteraction `json:"interactions" title:"Interactions" description:"Recorded requests and responses"`
+}
+
+type ClientInteraction struct {
+	Method     string   `json:"method" required:"true" title:"Method" colSpan:"col-span-2"`
+	URL        string   `json:"url" required:"true" title:"URL" colSpan:"col-span-6"`
+	BodyHash   string   `json:"bodyHash" required:"true" title:"Body hash" description:"SHA-256 of the request body" colSpan:"col-span-4"`
+	Status     string   `json:"status" title:"Status" colSpan:"col-span-6"`
+	StatusCode int      `json:"statusCode" title:"Status code" colSpan:"col-span-6"`
+	Headers    []Header `json:"headers" title:"Headers"`
+	Body       string   `json:"body" title:"Body" format:"textarea"`
+	Base64     bool     `json:"base64" title:"Base64" description:"Body is base64 encoded binary"`
+}
+
+type cassetteFile struct {
+	Interactions []ClientInteraction `json:"interactions"`
+}
+
+// cassette keeps interactions matched by method, URL and request body hash
+type cassette struct {
+	lock *sync.Mutex
+	// mode is record or replay, it never changes so settings update can not pair it with another cassette
+	mode         string
+	file         string
+	interactions []ClientInteraction
+}
+
+func newCassette(settings ClientCassette) (*cassette, error) {
+	c := &cassette{
+		lock:         &sync.Mutex{},
+		mode:         settings.Mode,
+		file:         settings.File,
+		interactions: settings.Interactions,
+	}
+	if c.file == "" {
+		return c, nil
+	}
+
+	data, err := os.ReadFile(c.file)
+	if errors.Is(err, fs.ErrNotExist) && settings.Mode == CassetteRecord {
+		// new cassette
+		c.interactions = nil
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read cassette: %v", err)
+	}
+	var f cassetteFile
+	if err = json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("invalid cassette %s: %v", c.file, err)
+	}
+	c.interactions = f.Interactions
+	return c, nil
+}
+
+func bodyHash(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+func (i ClientInteraction) matches(method string, url string, hash string) bool {
+	return strings.EqualFold(i.Method, method) && i.URL == url && i.BodyHash == hash
+}
+
+// find returns recorded response of the request
+func (c *cassette) find(method string, url string, body []byte) (*clientResponse, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	hash := bodyHash(body)
+	for _, i := range c.interactions {
+		if !i.matches(method, url, hash) {
+			continue
+		}
+		header := http.Header{}
+		for _, h := range i.Headers {
+			header.Add(h.Key, h.Value)
+		}
+		return &clientResponse{
+			status:     i.Status,
+			statusCode: i.StatusCode,
+			header:     header,
+			body:       []byte(i.Body),
+			encoded:    i.Base64,
+		}, true
+	}
+	return nil, false
+}
+
+// record adds interaction replacing previous recording of the same request, cassette file is rewritten if used
+func (c *cassette) record(method string, url string, body []byte, resp *clientResponse) error {
+	interaction := ClientInteraction{
+		Method:     strings.ToUpper(method),
+		URL:        url,
+		BodyHash:   bodyHash(body),
+		Status:     resp.status,
+		StatusCode: resp.statusCode,
+		Headers:    make([]Header, 0),
+		Body:       string(resp.body),
+		Base64:     resp.encoded,
+	}
+	for k, v := range resp.header {
+		for _, vv := range v {
+			interaction.Headers = append(interaction.Headers, Header{Key: k, Value: vv})
+		}
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	replaced := false
+	for idx, i := range c.interactions {
+		if i.matches(interaction.Method, interaction.URL, interaction.BodyHash) {
+			c.interactions[idx] = interaction
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		c.interactions = append(c.interactions, interaction)
+	}
+
+	if c.file == "" {
+		return nil
+	}
+	return c.write()
+}
+
+// write saves cassette file atomically so replay never reads a partial one
+func (c *cassette) write() error {
+	data, err := json.MarshalIndent(cassetteFile{Interactions: c.interactions}, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.file), filepath.Base(c.file)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.file)
+}
+
+func (c *cassette) list() []ClientInteraction {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	list := make([]ClientInteraction, len(c.interactions))
+	copy(list, c.interactions)
+	return list
+}
@@ -0,0 +1,185 @@
+package http
+
+import (
+	"context"
+	"github.com/tiny-systems/module/module"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func Test_cassette(t *testing.T) {
+	c, err := newCassette(ClientCassette{Mode: CassetteRecord})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := &clientResponse{status: "200 OK", statusCode: 200, header: http.Header{"X-A": {"1"}}, body: []byte("first")}
+	if err = c.record("get", "http://a/1", nil, resp); err != nil {
+		t.Fatal(err)
+	}
+	resp.body = []byte("second")
+	if err = c.record("GET", "http://a/1", nil, resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.list()) != 1 {
+		t.Fatalf("recording the same request should replace previous interaction")
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		url    string
+		body   []byte
+		want   string
+		wantOk bool
+	}{
+		{name: "match", method: "GET", url: "http://a/1", want: "second", wantOk: true},
+		{name: "other url", method: "GET", url: "http://a/2"},
+		{name: "other method", method: "POST", url: "http://a/1"},
+		{name: "other body", method: "GET", url: "http://a/1", body: []byte("x")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := c.find(tt.method, tt.url, tt.body)
+			if ok != tt.wantOk {
+				t.Fatalf("find() ok = %v, want %v", ok, tt.wantOk)
+			}
+			if ok && (string(got.body) != tt.want || got.header.Get("X-A") != "1" || got.statusCode != 200) {
+				t.Errorf("find() = %+v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_cassette(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set(HeaderContentType, MIMEApplicationJSON)
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	file := filepath.Join(t.TempDir(), "cassette.json")
+	request := ClientRequest{
+		Request: ClientRequestRequest{
+			Method:      http.MethodPost,
+			URL:         server.URL,
+			ContentType: MIMEApplicationJSON,
+			Body:        map[string]any{"a": 1},
+		},
+	}
+
+	var (
+		got        []ClientResponse
+		reconciled int
+	)
+	handler := func(ctx context.Context, port string, data any) error {
+		switch port {
+		case ClientResponsePort:
+			got = append(got, data.(ClientResponse))
+		case module.ReconcilePort:
+			reconciled++
+		}
+		return nil
+	}
+
+	tests := []struct {
+		name     string
+		cassette ClientCassette
+	}{
+		{name: "settings", cassette: ClientCassette{}},
+		{name: "file", cassette: ClientCassette{File: file}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls, reconciled, got = 0, 0, nil
+
+			recorder := (&Client{}).Instance().(*Client)
+			tt.cassette.Mode = CassetteRecord
+			if err := recorder.Handle(context.Background(), handler, module.SettingsPort, ClientRequestSettings{Cassette: tt.cassette}); err != nil {
+				t.Fatal(err)
+			}
+			if err := recorder.Handle(context.Background(), handler, ClientRequestPort, request); err != nil {
+				t.Fatal(err)
+			}
+			if calls != 1 {
+				t.Fatalf("record mode should send request")
+			}
+
+			// replay by a fresh instance, interactions come from the settings shown by the recorder
+			replay := recorder.Ports()[2].Configuration.(ClientRequestSettings).Cassette
+			if tt.cassette.File == "" && (reconciled != 1 || len(replay.Interactions) != 1) {
+				t.Fatalf("recorded interaction should be in settings")
+			}
+			replay.Mode = CassetteReplay
+
+			player := (&Client{}).Instance().(*Client)
+			if err := player.Handle(context.Background(), handler, module.SettingsPort, ClientRequestSettings{Cassette: replay}); err != nil {
+				t.Fatal(err)
+			}
+			if err := player.Handle(context.Background(), handler, ClientRequestPort, request); err != nil {
+				t.Fatal(err)
+			}
+			if calls != 1 {
+				t.Errorf("replay should not send requests")
+			}
+			if len(got) != 2 || got[1].Response.StatusCode != 200 || got[1].Response.Body.(map[string]any)["ok"] != true {
+				t.Errorf("unexpected replayed response %+v", got)
+			}
+
+			other := request
+			other.Request.Body = map[string]any{"a": 2}
+			if err := player.Handle(context.Background(), handler, ClientRequestPort, other); err == nil {
+				t.Errorf("request with other body should not be replayed")
+			}
+		})
+	}
+}
+
+func TestClient_cassetteSettingsUpdate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("live"))
+	}))
+	defer server.Close()
+
+	replay := ClientRequestSettings{Cassette: ClientCassette{
+		Mode: CassetteReplay,
+		Interactions: []ClientInteraction{{
+			Method: http.MethodGet, URL: server.URL, BodyHash: bodyHash(nil), StatusCode: 200, Body: "replayed",
+		}},
+	}}
+	record := ClientRequestSettings{Cassette: ClientCassette{Mode: CassetteRecord}}
+
+	client := (&Client{}).Instance().(*Client)
+	handler := func(ctx context.Context, port string, data any) error {
+		return nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			settings := replay
+			if i%2 == 1 {
+				settings = record
+			}
+			if err := client.Handle(context.Background(), handler, module.SettingsPort, settings); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+
+	// replay mode is never paired with the recording cassette, so every request is answered
+	for i := 0; i < 200; i++ {
+		err := client.Handle(context.Background(), handler, ClientRequestPort, ClientRequest{
+			Request: ClientRequestRequest{Method: http.MethodGet, URL: server.URL},
+		})
+		if err != nil {
+			t.Fatalf("Handle() error = %v", err)
+		}
+	}
+	<-done
+}
@@ -3,14 +3,16 @@ package http
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"github.com/clbanning/mxj/v2"
 	"github.com/spyzhov/ajson"
 	"github.com/tiny-systems/module/module"
 	"github.com/tiny-systems/module/registry"
-	"io"
 	"net/http"
+	"net/url"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -29,7 +31,10 @@ type Header struct {
 type ClientRequestContext any
 
 type ClientRequestSettings struct {
-	EnableErrorPort bool `json:"enableErrorPort" required:"true" title:"Enable Error Port" description:"If request may fail, error port will emit an error message"`
+	EnableErrorPort bool                 `json:"enableErrorPort" required:"true" title:"Enable Error Port" description:"If request may fail, error port will emit an error message"`
+	CircuitBreaker  ClientCircuitBreaker `json:"circuitBreaker" title:"Circuit breaker" description:"Stop calling hosts which keep failing"`
+	Transport       ClientTransport      `json:"transport" title:"Transport" description:"Connection, proxy, TLS and redirect options shared by all requests"`
+	Cassette        ClientCassette       `json:"cassette" title:"Cassette" description:"Record responses to replay them later without network"`
 }
 
 type ClientRequest struct {
@@ -42,24 +47,33 @@ type ClientRequestRequest struct {
 	Method  string `json:"method" required:"true" title:"Method" enum:"GET,POST,PATCH,PUT,DELETE" enumTitles:"GET,POST,PATCH,PUT,DELETE" colSpan:"col-span-6"`
 	Timeout int    `json:"timeout" required:"true" title:"Request Timeout" colSpan:"col-span-6"`
 
-	URL                 string      `json:"url" required:"true" title:"URL" format:"uri"`
-	ContentType         ContentType `json:"contentType" title:"Request Content Type" required:"true"`
-	Headers             []Header    `json:"headers" required:"true" title:"Headers"`
-	Body                any         `json:"body" configurable:"true" title:"Request Body"`
-	ResponseContentType ContentType `json:"responseContentType,omitempty" title:"Response Content Type" description:"Override response content type"`
+	URL                 string             `json:"url" required:"true" title:"URL" format:"uri"`
+	ContentType         ContentType        `json:"contentType" title:"Request Content Type" required:"true"`
+	Headers             []Header           `json:"headers" required:"true" title:"Headers"`
+	Body                any                `json:"body" configurable:"true" title:"Request Body" description:"Encoded according to content type. Multipart body is an object with fields and files having base64 content"`
+	ResponseContentType ContentType        `json:"responseContentType,omitempty" title:"Response Content Type" description:"Override response content type"`
+	Retry               ClientRetry        `json:"retry" title:"Retry" description:"Retry policy for failed requests"`
+	Auth                ClientAuth         `json:"auth" title:"Auth" description:"Credentials added to the request"`
+	Pagination          ClientPagination   `json:"pagination" title:"Pagination" description:"Follow pages of paginated APIs"`
+	Extract             []ClientExtraction `json:"extract" title:"Extract fields" description:"Named fields picked from JSON, XML or text response body. Binary responses fail extraction"`
+	MaxResponseSize     int64              `json:"maxResponseSize" title:"Max response size" description:"Bytes, zero means unlimited. Larger responses fail without retries" minimum:"0"`
 }
 
 type ClientResponse struct {
 	Context  ClientRequestContext   `json:"context" configurable:"true" required:"true" title:"Context" description:"Message to be sent further"`
 	Request  ClientRequestRequest   `json:"request" title:"Request" required:"true" description:"HTTP Request"`
 	Response ClientResponseResponse `json:"response" title:"Response" required:"true" description:"HTTP Response"`
+	Attempts int                    `json:"attempts" title:"Attempts" description:"Number of attempts made"`
+	Page     int                    `json:"page" title:"Page" description:"Page number starting with 1, number of pages for combined array"`
 }
 
 type ClientResponseResponse struct {
-	Headers    []Header `json:"headers" required:"true" title:"Headers"`
-	Status     string   `json:"status"`
-	StatusCode int      `json:"statusCode"`
-	Body       any      `json:"response" required:"true" title:"Body"`
+	Headers    []Header       `json:"headers" required:"true" title:"Headers"`
+	Status     string         `json:"status"`
+	StatusCode int            `json:"statusCode"`
+	Body       any            `json:"response" required:"true" title:"Body"`
+	Base64     bool           `json:"base64" title:"Base64" description:"Body is base64 encoded binary data"`
+	Fields     map[string]any `json:"fields" title:"Fields" description:"Extracted fields"`
 }
 
 type ClientError struct {
@@ -67,14 +81,58 @@ type ClientError struct {
 	Request  ClientRequestRequest   `json:"request" required:"true"`
 	Response ClientResponseResponse `json:"response"`
 	Error    string                 `json:"error" required:"true"`
+	Attempts int                    `json:"attempts" title:"Attempts" description:"Number of attempts made, zero if circuit breaker rejected the request"`
 }
 
 type Client struct {
 	settings ClientRequestSettings
+	breaker  *circuitBreaker
+	tokens   *tokenCache
+
+	// client keeps connection pool between requests
+	client *http.Client
+	// cassette is nil when record and replay are off
+	cassette *cassette
+	// lock guards settings, client and cassette
+	lock *sync.Mutex
 }
 
 func (h *Client) Instance() module.Component {
-	return &Client{}
+	settings := ClientRequestSettings{
+		CircuitBreaker: ClientCircuitBreaker{
+			FailureThreshold: 5,
+			OpenTimeout:      30,
+		},
+		Transport: ClientTransport{
+			Redirects:           RedirectFollow,
+			MaxRedirects:        defaultMaxRedirects,
+			MaxIdleConnsPerHost: 10,
+		},
+		Cassette: ClientCassette{
+			Mode:         CassetteOff,
+			Interactions: make([]ClientInteraction, 0),
+		},
+	}
+	// default transport settings are always valid
+	client, _ := settings.Transport.newHTTPClient()
+
+	return &Client{
+		settings: settings,
+		breaker:  newCircuitBreaker(),
+		tokens:   newTokenCache(),
+		client:   client,
+		lock:     &sync.Mutex{},
+	}
+}
+
+// clientResponse is fully read response of a single attempt
+type clientResponse struct {
+	status     string
+	statusCode int
+	header     http.Header
+	body       []byte
+	// body is base64 encoded binary
+	encoded bool
 }
 
 func (h *Client) GetInfo() module.ComponentInfo {
@@ -95,7 +153,27 @@ func (h *Client) Handle(ctx context.Context, handler module.Handler, port string
 		if !ok {
 			return fmt.Errorf("invalid settings")
 		}
+		client, err := in.Transport.newHTTPClient()
+		if err != nil {
+			return err
+		}
+
+		var c *cassette
+		if in.Cassette.Mode == CassetteRecord || in.Cassette.Mode == CassetteReplay {
+			if c, err = newCassette(in.Cassette); err != nil {
+				return err
+			}
+		}
+		h.lock.Lock()
+		prev := h.client
 		h.settings = in
+		h.client = client
+		h.cassette = c
+		h.lock.Unlock()
+
+		if prev != nil {
+			prev.CloseIdleConnections()
+		}
 
 		return nil
 
@@ -104,145 +182,339 @@ func (h *Client) Handle(ctx context.Context, handler module.Handler, port string
 		if !ok {
 			return fmt.Errorf("invalid message")
 		}
+		request := in.Request
+		// credentials are not passed further
+		in.Request.Auth = in.Request.Auth.redacted()
 
-		ctx, cancel := context.WithTimeout(ctx, time.Second*time.Duration(in.Request.Timeout))
-		defer cancel()
+		pagination := request.Pagination
+		if err := pagination.validate(); err != nil {
+			return h.sendError(ctx, handler, in, ClientResponseResponse{}, 0, err)
+		}
+
+		requestBody, contentType, err := encodeRequestBody(request.ContentType, request.Body)
+		if err != nil {
+			return h.sendError(ctx, handler, in, ClientResponseResponse{}, 0, err)
+		}
+
+		if request.URL, err = pagination.pageURL(request.URL, 1); err != nil {
+			return h.sendError(ctx, handler, in, ClientResponseResponse{}, 0, err)
+		}
 
-		var requestBody []byte
+		var (
+			response      ClientResponseResponse
+			page          int
+			totalAttempts int
+			combined      = make([]any, 0)
+		)
 
-		switch in.Request.ContentType {
-		case MIMEApplicationXML:
+		for page = 1; ; page++ {
+			in.Request.URL = request.URL
 
-		case MIMEApplicationJSON:
+			resp, attempts, err := h.exchange(ctx, handler, request, requestBody, contentType)
+			totalAttempts += attempts
+			if err != nil {
+				return h.sendError(ctx, handler, in, ClientResponseResponse{}, attempts, err)
+			}
 
-		case MIMETextHTML:
+			response, err = decodeResponse(resp, request)
+			if err != nil {
+				return h.sendError(ctx, handler, in, ClientResponseResponse{}, attempts, err)
+			}
 
-		case MimeTextPlain:
+			if resp.statusCode >= 400 {
+				// error range
+				// send to error port
+				return h.sendError(ctx, handler, in, response, attempts, fmt.Errorf("%v", response.Body))
+			}
 
-		case MIMEApplicationForm:
+			items, err := pagination.items(resp.body, response.Body)
+			if err != nil {
+				return h.sendError(ctx, handler, in, response, attempts, err)
+			}
 
-		case MIMEMultipartForm:
+			if pagination.Emit == PaginationEmitCombined {
+				combined = append(combined, items...)
+			} else if err = handler(ctx, ClientResponsePort, ClientResponse{
+				Request:  in.Request,
+				Response: response,
+				Context:  in.Context,
+				Attempts: attempts,
+				Page:     page,
+			}); err != nil {
+				return err
+			}
+
+			if page >= pagination.maxPages() {
+				break
+			}
+			next, err := pagination.next(request.URL, resp, page, len(items))
+			if err != nil {
+				return h.sendError(ctx, handler, in, response, attempts, err)
+			}
+			if next == "" {
+				break
+			}
+			request.URL = next
 		}
 
-		req, err := http.NewRequestWithContext(ctx, in.Request.Method, in.Request.URL, bytes.NewReader(requestBody))
-		if err != nil {
-			return err
+		if !pagination.enabled() || pagination.Emit != PaginationEmitCombined {
+			return nil
 		}
-		for _, header := range in.Request.Headers {
-			req.Header.Set(header.Key, header.Value)
+
+		// combined array goes with headers and status of the last page
+		response.Body = combined
+		return handler(ctx, ClientResponsePort, ClientResponse{
+			Request:  in.Request,
+			Response: response,
+			Context:  in.Context,
+			Attempts: totalAttempts,
+			Page:     page,
+		})
+
+	default:
+		return fmt.Errorf("port %s is not supoprted", port)
+	}
+
+}
+
+// decodeResponse decodes response body according to its content type and extracts requested fields
+func decodeResponse(resp *clientResponse, r ClientRequestRequest) (ClientResponseResponse, error) {
+	cType := resp.header.Get(HeaderContentType)
+	b := resp.body
+
+	var (
+		result interface{}
+		isJSON bool
+	)
+
+	switch {
+	case resp.encoded:
+		result = string(b)
+
+	case strings.HasPrefix(cType, MIMEApplicationJSON) || r.ResponseContentType == MIMEApplicationJSON:
+		isJSON = true
+		root, err := ajson.Unmarshal(b)
+		if err != nil {
+			return ClientResponseResponse{}, err
 		}
-		c := http.Client{}
-		resp, err := c.Do(req)
+
+		result, err = root.Unpack()
 		if err != nil {
-			return err
+			return ClientResponseResponse{}, err
 		}
-		defer resp.Body.Close()
 
-		cType := resp.Header.Get(HeaderContentType)
+	case strings.HasPrefix(cType, MIMEApplicationXML) || strings.HasPrefix(cType, MIMETextXML) || r.ResponseContentType == MIMEApplicationXML:
 
-		b, err := io.ReadAll(resp.Body)
+		mxj.SetAttrPrefix("")
+		m, err := mxj.NewMapXml(b, false)
 		if err != nil {
-			return err
+			return ClientResponseResponse{}, err
 		}
 
-		var result interface{}
+		result = m.Old()
 
-		switch {
-		case strings.HasPrefix(cType, MIMEApplicationJSON) || in.Request.ResponseContentType == MIMEApplicationJSON:
-			root, err := ajson.Unmarshal(b)
+	default:
+		builder := strings.Builder{}
+		builder.Write(b)
+		result = builder.String()
+	}
 
-			if err != nil {
-				if !h.settings.EnableErrorPort {
-					return err
-				}
-				return handler(ctx, ClientErrorPort, ClientError{
-					Request: in.Request,
-					Error:   err.Error(),
-				})
-			}
+	var headers []Header
+	for k, v := range resp.header {
+		for _, vv := range v {
+			headers = append(headers, Header{
+				Key:   k,
+				Value: vv,
+			})
+		}
+	}
 
-			result, err = root.Unpack()
-			if err != nil {
-				if !h.settings.EnableErrorPort {
-					return err
-				}
-				return handler(ctx, ClientErrorPort, ClientError{
-					Request: in.Request,
-					Error:   err.Error(),
-				})
-			}
+	response := ClientResponseResponse{
+		Body:       result,
+		Headers:    headers,
+		Status:     resp.status,
+		StatusCode: resp.statusCode,
+		Base64:     resp.encoded,
+	}
+	if resp.encoded {
+		if len(r.Extract) > 0 {
+			return response, fmt.Errorf("unable to extract fields from binary response of %s content type", cType)
+		}
+		return response, nil
+	}
 
-		case strings.HasPrefix(cType, MIMEApplicationXML) || strings.HasPrefix(cType, MIMETextXML) || in.Request.ResponseContentType == MIMEApplicationXML:
+	fields, err := extractFields(r.Extract, b, isJSON, result)
+	if err != nil {
+		return response, err
+	}
+	response.Fields = fields
+	return response, nil
+}
 
-			mxj.SetAttrPrefix("")
-			m, err := mxj.NewMapXml(b, false)
-			if err != nil {
-				if !h.settings.EnableErrorPort {
-					return err
-				}
-				return handler(ctx, ClientErrorPort, ClientError{
-					Request: in.Request,
-					Error:   err.Error(),
-				})
-			}
+func (h *Client) getSettings() ClientRequestSettings {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.settings
+}
 
-			result = m.Old()
+func (h *Client) httpClient() *http.Client {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.client
+}
+
+func (h *Client) getCassette() *cassette {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.cassette
+}
 
-		default:
-			builder := strings.Builder{}
-			builder.Write(b)
-			result = builder.String()
+// exchange sends request or replays its recorded response, responses are recorded in record mode
+func (h *Client) exchange(ctx context.Context, handler module.Handler, r ClientRequestRequest, body []byte, contentType string) (*clientResponse, int, error) {
+	c := h.getCassette()
+	if c == nil {
+		return h.send(ctx, r, body, contentType)
+	}
+
+	if c.mode == CassetteReplay {
+		resp, ok := c.find(r.Method, r.URL, body)
+		if !ok {
+			return nil, 0, fmt.Errorf("no recorded response for %s %s", r.Method, r.URL)
 		}
+		return resp, 1, nil
+	}
+
+	resp, attempts, err := h.send(ctx, r, body, contentType)
+	if err != nil {
+		return resp, attempts, err
+	}
+	if err = c.record(r.Method, r.URL, body, resp); err != nil {
+		return resp, attempts, fmt.Errorf("unable to record response: %v", err)
+	}
+	if c.file == "" {
+		// recorded interactions are shown in settings
+		_ = handler(ctx, module.ReconcilePort, nil)
+	}
+	return resp, attempts, nil
+}
+
+// sendError emits error to the error port if it's enabled, returns the error otherwise
+func (h *Client) sendError(ctx context.Context, handler module.Handler, in ClientRequest, response ClientResponseResponse, attempts int, err error) error {
+	if !h.getSettings().EnableErrorPort {
+		return err
+	}
+	return handler(ctx, ClientErrorPort, ClientError{
+		Context:  in.Context,
+		Request:  in.Request,
+		Response: response,
+		Error:    err.Error(),
+		Attempts: attempts,
+	})
+}
 
-		var headers []Header
-		for k, v := range resp.Header {
-			for _, vv := range v {
-				headers = append(headers, Header{
-					Key:   k,
-					Value: vv,
-				})
+// send performs request following retry policy and circuit breaker, returns response of the last attempt
+func (h *Client) send(ctx context.Context, r ClientRequestRequest, body []byte, contentType string) (*clientResponse, int, error) {
+	u, err := url.Parse(r.URL)
+	if err != nil {
+		return nil, 0, err
+	}
+	breaker := h.getSettings().CircuitBreaker
+
+	var (
+		resp     *clientResponse
+		attempts int
+	)
+
+	for {
+		if breaker.Enable && !h.breaker.allow(breaker, u.Host, time.Now()) {
+			if resp != nil || err != nil {
+				// keep the outcome of the last attempt
+				return resp, attempts, err
 			}
+			return nil, attempts, fmt.Errorf("circuit breaker is open for %s", u.Host)
 		}
 
-		if resp.StatusCode >= 400 {
-			// error range
-			// send to error port
+		attempts++
+		resp, err = h.do(ctx, r, body, contentType)
+		// host did respond when the body is too large
+		tooLarge := errors.Is(err, errResponseTooLarge)
+
+		if breaker.Enable {
+			h.breaker.record(breaker, u.Host, !tooLarge && (err != nil || resp.statusCode >= http.StatusInternalServerError), time.Now())
+		}
+
+		if tooLarge || ctx.Err() != nil || attempts >= r.Retry.MaxAttempts {
+			return resp, attempts, err
+		}
 
-			if !h.settings.EnableErrorPort {
-				return fmt.Errorf(fmt.Sprint(result))
+		var header http.Header
+		if err == nil {
+			if !r.Retry.retryStatus(resp.statusCode) {
+				return resp, attempts, nil
 			}
+			header = resp.header
+		}
 
-			return handler(ctx, ClientErrorPort, ClientError{
-				Context: in.Context,
-				Request: in.Request,
-				Error:   fmt.Sprint(result),
-				Response: ClientResponseResponse{
-					Body:       result,
-					Headers:    headers,
-					Status:     resp.Status,
-					StatusCode: resp.StatusCode,
-				},
-			})
+		select {
+		case <-ctx.Done():
+			return resp, attempts, err
+		case <-time.After(r.Retry.delay(attempts, header)):
 		}
+	}
+}
 
-		return handler(ctx, ClientResponsePort, ClientResponse{
-			Request: in.Request,
-			Response: ClientResponseResponse{
-				Body:       result,
-				Headers:    headers,
-				Status:     resp.Status,
-				StatusCode: resp.StatusCode,
-			},
-			Context: in.Context,
-		})
+// do performs single attempt within request timeout, content type is set unless request headers override it
+func (h *Client) do(ctx context.Context, r ClientRequestRequest, body []byte, contentType string) (*clientResponse, error) {
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Second*time.Duration(r.Timeout))
+		defer cancel()
+	}
 
-	default:
-		return fmt.Errorf("port %s is not supoprted", port)
+	req, err := http.NewRequestWithContext(ctx, r.Method, r.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set(HeaderContentType, contentType)
+	}
+	for _, header := range r.Headers {
+		req.Header.Set(header.Key, header.Value)
+	}
+	c := h.httpClient()
+	if err = r.Auth.authorize(ctx, req, h.tokens, c); err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized && r.Auth.Type == ClientAuthOAuth2 {
+		// token could be revoked, request a new one next time
+		h.tokens.invalidate(r.Auth)
 	}
 
+	b, encoded, err := readBody(resp, r.ResponseContentType, r.MaxResponseSize)
+	if err != nil {
+		return nil, err
+	}
+	return &clientResponse{
+		status:     resp.Status,
+		statusCode: resp.StatusCode,
+		header:     resp.Header,
+		body:       b,
+		encoded:    encoded,
+	}, nil
 }
 
 func (h *Client) Ports() []module.Port {
+	settings := h.getSettings()
+	if c := h.getCassette(); c != nil && c.file == "" {
+		settings.Cassette.Interactions = c.list()
+	}
+
 	ports := []module.Port{
 		{
 			Name:   ClientRequestPort,
@@ -250,11 +522,35 @@ func (h *Client) Ports() []module.Port {
 			Source: true,
 			Configuration: ClientRequest{
 				Request: ClientRequestRequest{
-					Method:      http.MethodGet,
-					Headers:     make([]Header, 0),
-					URL:         "http://example.com",
-					Timeout:     10,
-					ContentType: "application/json",
+					Method:          http.MethodGet,
+					Headers:         make([]Header, 0),
+					URL:             "http://example.com",
+					Timeout:         10,
+					ContentType:     "application/json",
+					Extract:         make([]ClientExtraction, 0),
+					MaxResponseSize: 10 << 20,
+					Auth: ClientAuth{
+						Type:       ClientAuthNone,
+						APIKeyName: "X-API-Key",
+						APIKeyIn:   APIKeyInHeader,
+					},
+					Pagination: ClientPagination{
+						Mode:        PaginationNone,
+						MaxPages:    defaultMaxPages,
+						Emit:        PaginationEmitPage,
+						CursorParam: "cursor",
+						PageParam:   "page",
+						StartPage:   1,
+						OffsetParam: "offset",
+					},
+					Retry: ClientRetry{
+						MaxAttempts:       1,
+						BackoffBase:       200,
+						BackoffMax:        10000,
+						Jitter:            true,
+						RetryStatusCodes:  []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+						RespectRetryAfter: true,
+					},
 				},
 			},
 			Position: module.Left,
@@ -270,12 +566,12 @@ func (h *Client) Ports() []module.Port {
 		{
 			Name:          module.SettingsPort,
 			Label:         "Settings",
-			Configuration: h.settings,
+			Configuration: settings,
 			Source:        true,
 		},
 	}
 
-	if !h.settings.EnableErrorPort {
+	if !settings.EnableErrorPort {
 		return ports
 	}
 
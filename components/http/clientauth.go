@@ -0,0 +1,156 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	ClientAuthNone   = "none"
+	ClientAuthBasic  = "basic"
+	ClientAuthBearer = "bearer"
+	ClientAuthAPIKey = "apiKey"
+	ClientAuthOAuth2 = "oauth2"
+)
+
+const (
+	APIKeyInHeader = "header"
+	APIKeyInQuery  = "query"
+)
+
+type ClientAuth struct {
+	Type string `json:"type" title:"Type" enum:"none,basic,bearer,apiKey,oauth2" enumTitles:"None,Basic,Bearer token,API key,OAuth2 client credentials" default:"none"`
+
+	Username string `json:"username,omitempty" title:"Username" colSpan:"col-span-6"`
+	Password string `json:"password,omitempty" title:"Password" colSpan:"col-span-6"`
+
+	Token string `json:"token,omitempty" title:"Bearer token"`
+
+	APIKeyName  string `json:"apiKeyName,omitempty" title:"API key name" description:"Header or query parameter name" colSpan:"col-span-4"`
+	APIKeyValue string `json:"apiKeyValue,omitempty" title:"API key" colSpan:"col-span-4"`
+	APIKeyIn    string `json:"apiKeyIn,omitempty" title:"API key in" enum:"header,query" enumTitles:"Header,Query" colSpan:"col-span-4"`
+
+	TokenURL     string   `json:"tokenURL,omitempty" title:"Token URL" format:"uri"`
+	ClientID     string   `json:"clientID,omitempty" title:"Client ID" colSpan:"col-span-6"`
+	ClientSecret string   `json:"clientSecret,omitempty" title:"Client secret" colSpan:"col-span-6"`
+	Scopes       []string `json:"scopes,omitempty" title:"Scopes"`
+}
+
+// redacted copy of auth settings safe to pass further in messages
+func (a ClientAuth) redacted() ClientAuth {
+	a.Password = ""
+	a.Token = ""
+	a.APIKeyValue = ""
+	a.ClientSecret = ""
+	return a
+}
+
+// authorize adds credentials to the request, token requests are sent by the given client
+func (a ClientAuth) authorize(ctx context.Context, req *http.Request, tokens *tokenCache, client *http.Client) error {
+	switch a.Type {
+	case "", ClientAuthNone:
+		return nil
+
+	case ClientAuthBasic:
+		req.SetBasicAuth(a.Username, a.Password)
+
+	case ClientAuthBearer:
+		req.Header.Set("Authorization", "Bearer "+a.Token)
+
+	case ClientAuthAPIKey:
+		if a.APIKeyName == "" {
+			return fmt.Errorf("API key name can not be empty")
+		}
+		if a.APIKeyIn == APIKeyInQuery {
+			q := req.URL.Query()
+			q.Set(a.APIKeyName, a.APIKeyValue)
+			req.URL.RawQuery = q.Encode()
+			return nil
+		}
+		req.Header.Set(a.APIKeyName, a.APIKeyValue)
+
+	case ClientAuthOAuth2:
+		token, err := tokens.token(context.WithValue(ctx, oauth2.HTTPClient, client), a)
+		if err != nil {
+			return fmt.Errorf("unable to get OAuth2 token: %v", err)
+		}
+		token.SetAuthHeader(req)
+
+	default:
+		return fmt.Errorf("unknown auth type %s", a.Type)
+	}
+	return nil
+}
+
+// tokenCache keeps OAuth2 client credentials tokens between requests, so a new token is requested only when the cached one expires
+type tokenCache struct {
+	lock    *sync.Mutex
+	clients map[string]*cachedToken
+}
+
+type cachedToken struct {
+	lock   *sync.Mutex
+	secret string
+	token  *oauth2.Token
+}
+
+func newTokenCache() *tokenCache {
+	return &tokenCache{
+		lock:    &sync.Mutex{},
+		clients: make(map[string]*cachedToken),
+	}
+}
+
+func tokenCacheKey(a ClientAuth) string {
+	return strings.Join([]string{a.TokenURL, a.ClientID, strings.Join(a.Scopes, " ")}, "\n")
+}
+
+func (c *tokenCache) token(ctx context.Context, a ClientAuth) (*oauth2.Token, error) {
+	if a.TokenURL == "" || a.ClientID == "" {
+		return nil, fmt.Errorf("token URL and client ID can not be empty")
+	}
+
+	key := tokenCacheKey(a)
+
+	c.lock.Lock()
+	entry, ok := c.clients[key]
+	if !ok {
+		entry = &cachedToken{lock: &sync.Mutex{}}
+		c.clients[key] = entry
+	}
+	c.lock.Unlock()
+
+	// concurrent requests of the same client wait for a single token request
+	entry.lock.Lock()
+	defer entry.lock.Unlock()
+
+	if entry.token.Valid() && entry.secret == a.ClientSecret {
+		return entry.token, nil
+	}
+
+	config := clientcredentials.Config{
+		ClientID:     a.ClientID,
+		ClientSecret: a.ClientSecret,
+		TokenURL:     a.TokenURL,
+		Scopes:       a.Scopes,
+	}
+	token, err := config.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	entry.token = token
+	entry.secret = a.ClientSecret
+	return token, nil
+}
+
+// invalidate drops cached token, e.g. when it was revoked before expiry
+func (c *tokenCache) invalidate(a ClientAuth) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.clients, tokenCacheKey(a))
+}
@@ -0,0 +1,110 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientAuth_authorize(t *testing.T) {
+	tests := []struct {
+		name       string
+		auth       ClientAuth
+		wantHeader string
+		wantValue  string
+		wantQuery  string
+		wantErr    bool
+	}{
+		{name: "none", auth: ClientAuth{Type: ClientAuthNone}},
+		{name: "basic", auth: ClientAuth{Type: ClientAuthBasic, Username: "user", Password: "pass"}, wantHeader: "Authorization", wantValue: "Basic dXNlcjpwYXNz"},
+		{name: "bearer", auth: ClientAuth{Type: ClientAuthBearer, Token: "abc"}, wantHeader: "Authorization", wantValue: "Bearer abc"},
+		{name: "api key header", auth: ClientAuth{Type: ClientAuthAPIKey, APIKeyName: "X-API-Key", APIKeyValue: "key"}, wantHeader: "X-API-Key", wantValue: "key"},
+		{name: "api key query", auth: ClientAuth{Type: ClientAuthAPIKey, APIKeyName: "api_key", APIKeyValue: "key", APIKeyIn: APIKeyInQuery}, wantQuery: "a=1&api_key=key"},
+		{name: "api key without name", auth: ClientAuth{Type: ClientAuthAPIKey, APIKeyValue: "key"}, wantErr: true},
+		{name: "unknown", auth: ClientAuth{Type: "digest"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://example.com/?a=1", nil)
+			err := tt.auth.authorize(context.Background(), req, newTokenCache(), http.DefaultClient)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("authorize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantHeader != "" && req.Header.Get(tt.wantHeader) != tt.wantValue {
+				t.Errorf("header %s = %q, want %q", tt.wantHeader, req.Header.Get(tt.wantHeader), tt.wantValue)
+			}
+			if tt.wantQuery != "" && req.URL.RawQuery != tt.wantQuery {
+				t.Errorf("query = %q, want %q", req.URL.RawQuery, tt.wantQuery)
+			}
+		})
+	}
+}
+
+func TestClient_oauth2(t *testing.T) {
+	tokenRequests := 0
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenRequests++
+		if id, secret, _ := r.BasicAuth(); id != "client" || secret != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set(HeaderContentType, MIMEApplicationJSON)
+		_, _ = w.Write([]byte(`{"access_token":"token","token_type":"bearer","expires_in":3600}`))
+	}))
+	defer tokenServer.Close()
+
+	revoked := false
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if revoked || r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	}))
+	defer api.Close()
+
+	client := (&Client{}).Instance().(*Client)
+
+	var got ClientResponse
+	handler := func(ctx context.Context, port string, data any) error {
+		got, _ = data.(ClientResponse)
+		return nil
+	}
+
+	request := ClientRequest{
+		Request: ClientRequestRequest{
+			Method: http.MethodGet,
+			URL:    api.URL,
+			Auth: ClientAuth{
+				Type:         ClientAuthOAuth2,
+				TokenURL:     tokenServer.URL,
+				ClientID:     "client",
+				ClientSecret: "secret",
+			},
+		},
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := client.Handle(context.Background(), handler, ClientRequestPort, request); err != nil {
+			t.Fatalf("Handle() error = %v", err)
+		}
+	}
+	if tokenRequests != 1 {
+		t.Errorf("token requests = %d, token should be cached between requests", tokenRequests)
+	}
+	if got.Request.Auth.ClientSecret != "" || got.Request.Auth.ClientID != "client" {
+		t.Errorf("client secret should not be passed further")
+	}
+
+	revoked = true
+	if err := client.Handle(context.Background(), handler, ClientRequestPort, request); err == nil {
+		t.Fatalf("Handle() should fail with revoked token")
+	}
+	revoked = false
+	if err := client.Handle(context.Background(), handler, ClientRequestPort, request); err != nil {
+		t.Fatalf("Handle() error = %v", err)
+	}
+	if tokenRequests != 2 {
+		t.Errorf("token requests = %d, token should be requested again after 401", tokenRequests)
+	}
+}
@@ -0,0 +1,100 @@
+package http
+
+import (
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type ServerCORS struct {
+	Enable           bool     `json:"enable" title:"Enable CORS" description:"Answer preflight requests and add CORS headers to every response"`
+	AllowOrigins     []string `json:"allowOrigins" title:"Allowed origins" description:"Exact origins, * for any origin or wildcard subdomains like https://*.example.com"`
+	AllowMethods     []string `json:"allowMethods" title:"Allowed methods"`
+	AllowHeaders     []string `json:"allowHeaders" title:"Allowed headers" description:"Leave empty to allow headers requested by the browser"`
+	ExposeHeaders    []string `json:"exposeHeaders" title:"Exposed headers"`
+	AllowCredentials bool     `json:"allowCredentials" title:"Allow credentials"`
+	MaxAge           int      `json:"maxAge" title:"Max age" description:"How long preflight results can be cached, seconds" minimum:"0"`
+}
+
+// allowOrigin returns value for Access-Control-Allow-Origin header, empty string if origin is not allowed
+func (s ServerCORS) allowOrigin(origin string) string {
+	if origin == "" {
+		return ""
+	}
+	for _, allowed := range s.AllowOrigins {
+		if allowed == "*" {
+			if s.AllowCredentials {
+				// wildcard is not accepted by browsers for credentialed requests
+				return origin
+			}
+			return "*"
+		}
+		if strings.EqualFold(allowed, origin) || matchOriginPattern(allowed, origin) {
+			return origin
+		}
+	}
+	return ""
+}
+
+func matchOriginPattern(pattern string, origin string) bool {
+	prefix, suffix, ok := strings.Cut(pattern, "*")
+	if !ok {
+		return false
+	}
+	origin = strings.ToLower(origin)
+	return len(origin) > len(prefix)+len(suffix) &&
+		strings.HasPrefix(origin, strings.ToLower(prefix)) &&
+		strings.HasSuffix(origin, strings.ToLower(suffix))
+}
+
+// handle adds CORS headers to the response, returns true if request was a preflight and it's already answered
+func (s ServerCORS) handle(c echo.Context) (bool, error) {
+	req := c.Request()
+	header := c.Response().Header()
+
+	origin := req.Header.Get(echo.HeaderOrigin)
+	header.Add(echo.HeaderVary, echo.HeaderOrigin)
+
+	preflight := req.Method == http.MethodOptions && req.Header.Get(echo.HeaderAccessControlRequestMethod) != ""
+
+	allowOrigin := s.allowOrigin(origin)
+	if allowOrigin == "" {
+		if preflight {
+			return true, c.NoContent(http.StatusNoContent)
+		}
+		return false, nil
+	}
+
+	header.Set(echo.HeaderAccessControlAllowOrigin, allowOrigin)
+	if s.AllowCredentials {
+		header.Set(echo.HeaderAccessControlAllowCredentials, "true")
+	}
+
+	if !preflight {
+		if len(s.ExposeHeaders) > 0 {
+			header.Set(echo.HeaderAccessControlExposeHeaders, strings.Join(s.ExposeHeaders, ","))
+		}
+		return false, nil
+	}
+
+	header.Add(echo.HeaderVary, echo.HeaderAccessControlRequestMethod)
+	header.Add(echo.HeaderVary, echo.HeaderAccessControlRequestHeaders)
+
+	methods := s.AllowMethods
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet, http.MethodHead, http.MethodPut, http.MethodPatch, http.MethodPost, http.MethodDelete}
+	}
+	header.Set(echo.HeaderAccessControlAllowMethods, strings.Join(methods, ","))
+
+	if len(s.AllowHeaders) > 0 {
+		header.Set(echo.HeaderAccessControlAllowHeaders, strings.Join(s.AllowHeaders, ","))
+	} else if requested := req.Header.Get(echo.HeaderAccessControlRequestHeaders); requested != "" {
+		header.Set(echo.HeaderAccessControlAllowHeaders, requested)
+	}
+
+	if s.MaxAge > 0 {
+		header.Set(echo.HeaderAccessControlMaxAge, strconv.Itoa(s.MaxAge))
+	}
+	return true, c.NoContent(http.StatusNoContent)
+}
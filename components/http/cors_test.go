@@ -0,0 +1,151 @@
+package http
+
+import (
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServerCORS_allowOrigin(t *testing.T) {
+	tests := []struct {
+		name        string
+		cors        ServerCORS
+		origin      string
+		wantAllowed string
+	}{
+		{
+			name:        "any origin",
+			cors:        ServerCORS{AllowOrigins: []string{"*"}},
+			origin:      "https://app.example.com",
+			wantAllowed: "*",
+		},
+		{
+			name:        "any origin with credentials echoes origin",
+			cors:        ServerCORS{AllowOrigins: []string{"*"}, AllowCredentials: true},
+			origin:      "https://app.example.com",
+			wantAllowed: "https://app.example.com",
+		},
+		{
+			name:        "exact origin is case insensitive",
+			cors:        ServerCORS{AllowOrigins: []string{"https://App.Example.com"}},
+			origin:      "https://app.example.com",
+			wantAllowed: "https://app.example.com",
+		},
+		{
+			name:   "exact origin mismatch",
+			cors:   ServerCORS{AllowOrigins: []string{"https://app.example.com"}},
+			origin: "https://app.example.com.evil.com",
+		},
+		{
+			name:        "wildcard subdomain",
+			cors:        ServerCORS{AllowOrigins: []string{"https://*.example.com"}},
+			origin:      "https://api.example.com",
+			wantAllowed: "https://api.example.com",
+		},
+		{
+			name:   "wildcard does not match bare domain",
+			cors:   ServerCORS{AllowOrigins: []string{"https://*.example.com"}},
+			origin: "https://.example.com",
+		},
+		{
+			name:   "wildcard does not match other domain suffix",
+			cors:   ServerCORS{AllowOrigins: []string{"https://*.example.com"}},
+			origin: "https://evil-example.com",
+		},
+		{
+			name:   "wildcard keeps scheme",
+			cors:   ServerCORS{AllowOrigins: []string{"https://*.example.com"}},
+			origin: "http://api.example.com",
+		},
+		{
+			name: "empty origin",
+			cors: ServerCORS{AllowOrigins: []string{"*"}},
+		},
+		{
+			name:   "no allowed origins",
+			cors:   ServerCORS{},
+			origin: "https://app.example.com",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cors.allowOrigin(tt.origin); got != tt.wantAllowed {
+				t.Errorf("allowOrigin() = %q, want %q", got, tt.wantAllowed)
+			}
+		})
+	}
+}
+
+func TestServerCORS_handle(t *testing.T) {
+	cors := ServerCORS{
+		AllowOrigins:     []string{"https://*.example.com"},
+		AllowCredentials: true,
+		MaxAge:           600,
+	}
+
+	tests := []struct {
+		name          string
+		method        string
+		origin        string
+		preflight     bool
+		wantPreflight bool
+		wantOrigin    string
+	}{
+		{
+			name:          "allowed preflight",
+			method:        http.MethodOptions,
+			origin:        "https://app.example.com",
+			preflight:     true,
+			wantPreflight: true,
+			wantOrigin:    "https://app.example.com",
+		},
+		{
+			name:          "rejected preflight is answered without CORS headers",
+			method:        http.MethodOptions,
+			origin:        "https://evil.com",
+			preflight:     true,
+			wantPreflight: true,
+		},
+		{
+			name:       "simple request",
+			method:     http.MethodGet,
+			origin:     "https://app.example.com",
+			wantOrigin: "https://app.example.com",
+		},
+		{
+			name:   "simple request from other origin",
+			method: http.MethodGet,
+			origin: "https://evil.com",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/", nil)
+			req.Header.Set(echo.HeaderOrigin, tt.origin)
+			if tt.preflight {
+				req.Header.Set(echo.HeaderAccessControlRequestMethod, http.MethodPost)
+			}
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(req, rec)
+
+			preflight, err := cors.handle(c)
+			if err != nil {
+				t.Fatalf("handle() error = %v", err)
+			}
+			if preflight != tt.wantPreflight {
+				t.Errorf("handle() preflight = %v, want %v", preflight, tt.wantPreflight)
+			}
+			if got := rec.Header().Get(echo.HeaderAccessControlAllowOrigin); got != tt.wantOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+			wantCredentials := ""
+			if tt.wantOrigin != "" {
+				wantCredentials = "true"
+			}
+			if got := rec.Header().Get(echo.HeaderAccessControlAllowCredentials); got != wantCredentials {
+				t.Errorf("Access-Control-Allow-Credentials = %q, want %q", got, wantCredentials)
+			}
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package http
+
+import (
+	"time"
+)
+
+// drain gives in-flight requests time to receive their responses, then closes abort channel so the rest are answered by the server
+func (h *Server) drain(timeout time.Duration, abortCh chan struct{}) {
+	defer close(abortCh)
+
+	deadline := time.Now().Add(timeout)
+	ticker := time.NewTicker(time.Millisecond * 100)
+	defer ticker.Stop()
+
+	for h.inFlight.Load() > 0 && time.Now().Before(deadline) {
+		<-ticker.C
+	}
+}
@@ -0,0 +1,115 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestServer_drain(t *testing.T) {
+	h := (&Server{}).Instance().(*Server)
+
+	t.Run("nothing in flight", func(t *testing.T) {
+		abortCh := make(chan struct{})
+		started := time.Now()
+		h.drain(time.Second*5, abortCh)
+
+		if time.Since(started) > time.Second {
+			t.Errorf("drain should not wait without in-flight requests")
+		}
+		if _, open := <-abortCh; open {
+			t.Errorf("abort channel should be closed")
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		h.inFlight.Store(1)
+		defer h.inFlight.Store(0)
+
+		abortCh := make(chan struct{})
+		started := time.Now()
+		h.drain(time.Millisecond*300, abortCh)
+
+		if waited := time.Since(started); waited < time.Millisecond*300 {
+			t.Errorf("drain waited %v, want drain timeout", waited)
+		}
+		if _, open := <-abortCh; open {
+			t.Errorf("abort channel should be closed")
+		}
+	})
+}
+
+func TestServer_drainInFlight(t *testing.T) {
+	h := (&Server{}).Instance().(*Server)
+
+	requests := make(chan ServerRequest, 2)
+	handler := func(ctx context.Context, port string, data any) error {
+		if req, ok := data.(ServerRequest); ok && port == ServerRequestPort {
+			// requests are answered while server stops
+			requests <- req
+		}
+		return nil
+	}
+
+	start := testServerStart()
+	start.DrainTimeout = 1
+	start.DrainStatusCode = http.StatusServiceUnavailable
+	url := startTestServer(t, h, start, handler)
+
+	statuses := make(map[string]chan int)
+	for _, path := range []string{"/answered", "/aborted"} {
+		ch := make(chan int, 1)
+		statuses[path] = ch
+		go func(path string) {
+			resp, err := http.Get(url + path)
+			if err != nil {
+				ch <- 0
+				return
+			}
+			resp.Body.Close()
+			ch <- resp.StatusCode
+		}(path)
+	}
+
+	ids := make(map[string]string)
+	for range statuses {
+		req := receive(t, requests)
+		ids[req.RequestURI] = req.RequestID
+	}
+
+	if err := h.Handle(context.Background(), handler, ServerStopPort, ServerStop{}); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(time.Second * 2); !h.draining.Load(); time.Sleep(time.Millisecond * 10) {
+		if time.Now().After(deadline) {
+			t.Fatalf("server is not draining")
+		}
+	}
+
+	err := h.Handle(context.Background(), handler, ServerResponsePort, ServerResponse{
+		RequestID:   ids["/answered"],
+		StatusCode:  http.StatusOK,
+		ContentType: MimeTextPlain,
+		Body:        "done",
+	})
+	if err != nil {
+		t.Fatalf("response during drain: %v", err)
+	}
+
+	if status := receive(t, statuses["/answered"]); status != http.StatusOK {
+		t.Errorf("answered request status = %d, want 200", status)
+	}
+	if status := receive(t, statuses["/aborted"]); status != http.StatusServiceUnavailable {
+		t.Errorf("aborted request status = %d, want 503", status)
+	}
+
+	for deadline := time.Now().Add(time.Second * 5); h.isRunning(); time.Sleep(time.Millisecond * 10) {
+		if time.Now().After(deadline) {
+			t.Fatalf("server did not stop")
+		}
+	}
+	if status := h.getStatus(); status.Drained != 1 || status.Aborted != 1 {
+		t.Errorf("drained = %d, aborted = %d, want 1 and 1", status.Drained, status.Aborted)
+	}
+}
@@ -0,0 +1,163 @@
+package http
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/clbanning/mxj/v2"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+type ClientMultipartBody struct {
+	Fields map[string]any        `json:"fields" title:"Fields"`
+	Files  []ClientMultipartFile `json:"files" title:"Files"`
+}
+
+type ClientMultipartFile struct {
+	Field       string `json:"field" required:"true" title:"Field name"`
+	Filename    string `json:"filename" required:"true" title:"Filename"`
+	ContentType string `json:"contentType" title:"Content type" description:"application/octet-stream if empty"`
+	Content     string `json:"content" title:"Content" description:"Base64 encoded file content"`
+}
+
+// encodeRequestBody encodes body according to content type, returns content type header value to send.
+// String bodies are sent as is, except plain strings sent as JSON
+func encodeRequestBody(contentType ContentType, body any) ([]byte, string, error) {
+	if body == nil {
+		return nil, "", nil
+	}
+
+	mediaType := strings.TrimSpace(strings.Split(string(contentType), ";")[0])
+
+	switch mediaType {
+	case MIMEApplicationJSON:
+		if s, ok := body.(string); ok && json.Valid([]byte(s)) {
+			return []byte(s), string(contentType), nil
+		}
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, "", fmt.Errorf("unable to encode JSON body: %v", err)
+		}
+		return b, string(contentType), nil
+
+	case MIMEApplicationXML, MIMETextXML:
+		if s, ok := body.(string); ok {
+			return []byte(s), string(contentType), nil
+		}
+		m, ok := body.(map[string]any)
+		if !ok {
+			return nil, "", fmt.Errorf("XML body should be an object")
+		}
+		b, err := mxj.Map(m).Xml()
+		if err != nil {
+			return nil, "", fmt.Errorf("unable to encode XML body: %v", err)
+		}
+		return b, string(contentType), nil
+
+	case MIMEApplicationForm:
+		if s, ok := body.(string); ok {
+			return []byte(s), string(contentType), nil
+		}
+		m, ok := body.(map[string]any)
+		if !ok {
+			return nil, "", fmt.Errorf("form body should be an object")
+		}
+		values := url.Values{}
+		for k, v := range m {
+			values[k] = formValues(v)
+		}
+		return []byte(values.Encode()), string(contentType), nil
+
+	case MIMEMultipartForm:
+		return encodeMultipart(body)
+
+	case "":
+		return []byte(fmt.Sprint(body)), MimeTextPlain, nil
+
+	default:
+		if b, ok := body.([]byte); ok {
+			return b, string(contentType), nil
+		}
+		return []byte(fmt.Sprint(body)), string(contentType), nil
+	}
+}
+
+func formValues(v any) []string {
+	switch vv := v.(type) {
+	case nil:
+		return []string{""}
+	case []any:
+		values := make([]string, 0, len(vv))
+		for _, item := range vv {
+			values = append(values, fmt.Sprint(item))
+		}
+		return values
+	case []string:
+		return vv
+	default:
+		return []string{fmt.Sprint(vv)}
+	}
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func encodeMultipart(body any) ([]byte, string, error) {
+	// body comes as generic map from the flow
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, "", err
+	}
+	var form ClientMultipartBody
+	if err = json.Unmarshal(data, &form); err != nil {
+		return nil, "", fmt.Errorf("invalid multipart body: %v", err)
+	}
+
+	buf := &bytes.Buffer{}
+	w := multipart.NewWriter(buf)
+
+	names := make([]string, 0, len(form.Fields))
+	for name := range form.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, v := range formValues(form.Fields[name]) {
+			if err = w.WriteField(name, v); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+
+	for _, f := range form.Files {
+		content, err := base64.StdEncoding.DecodeString(f.Content)
+		if err != nil {
+			return nil, "", fmt.Errorf("file %s: unable to decode base64 content: %v", f.Filename, err)
+		}
+		contentType := f.ContentType
+		if contentType == "" {
+			contentType = MIMEOctetStream
+		}
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, quoteEscaper.Replace(f.Field), quoteEscaper.Replace(f.Filename)))
+		header.Set(HeaderContentType, contentType)
+
+		part, err := w.CreatePart(header)
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err = part.Write(content); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if err = w.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), w.FormDataContentType(), nil
+}
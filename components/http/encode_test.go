@@ -0,0 +1,131 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_encodeRequestBody(t *testing.T) {
+	tests := []struct {
+		name            string
+		contentType     ContentType
+		body            any
+		want            string
+		wantContentType string
+		wantErr         bool
+	}{
+		{name: "no body", contentType: MIMEApplicationJSON},
+		{name: "json object", contentType: MIMEApplicationJSON, body: map[string]any{"a": 1}, want: `{"a":1}`, wantContentType: MIMEApplicationJSON},
+		{name: "json raw string", contentType: MIMEApplicationJSON, body: `{"a":1}`, want: `{"a":1}`, wantContentType: MIMEApplicationJSON},
+		{name: "json plain string", contentType: MIMEApplicationJSON, body: "hello", want: `"hello"`, wantContentType: MIMEApplicationJSON},
+		{name: "json with charset", contentType: "application/json; charset=utf-8", body: []any{1, 2}, want: `[1,2]`, wantContentType: "application/json; charset=utf-8"},
+		{name: "xml object", contentType: MIMEApplicationXML, body: map[string]any{"user": map[string]any{"name": "bob"}}, want: `<user><name>bob</name></user>`, wantContentType: MIMEApplicationXML},
+		{name: "xml not object", contentType: MIMEApplicationXML, body: 1, wantErr: true},
+		{name: "form", contentType: MIMEApplicationForm, body: map[string]any{"a": "1", "b": []any{"x", "y"}}, want: `a=1&b=x&b=y`, wantContentType: MIMEApplicationForm},
+		{name: "form raw string", contentType: MIMEApplicationForm, body: "a=1", want: `a=1`, wantContentType: MIMEApplicationForm},
+		{name: "plain text", contentType: MimeTextPlain, body: "hello", want: "hello", wantContentType: MimeTextPlain},
+		{name: "no content type", body: 42, want: "42", wantContentType: MimeTextPlain},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, contentType, err := encodeRequestBody(tt.contentType, tt.body)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("encodeRequestBody() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Errorf("encodeRequestBody() body = %q, want %q", got, tt.want)
+			}
+			if contentType != tt.wantContentType {
+				t.Errorf("encodeRequestBody() content type = %q, want %q", contentType, tt.wantContentType)
+			}
+		})
+	}
+}
+
+func Test_encodeRequestBodyMultipart(t *testing.T) {
+	body := map[string]any{
+		"fields": map[string]any{"title": "report"},
+		"files": []any{
+			map[string]any{"field": "file", "filename": "a.txt", "content": base64.StdEncoding.EncodeToString([]byte("hello"))},
+		},
+	}
+
+	data, contentType, err := encodeRequestBody(MIMEMultipartForm, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != MIMEMultipartForm || params["boundary"] == "" {
+		t.Fatalf("content type = %q, want multipart with boundary", contentType)
+	}
+
+	form, err := multipart.NewReader(bytes.NewReader(data), params["boundary"]).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := form.Value["title"]; len(v) != 1 || v[0] != "report" {
+		t.Errorf("title field = %v, want report", v)
+	}
+	files := form.File["file"]
+	if len(files) != 1 || files[0].Filename != "a.txt" || files[0].Header.Get(HeaderContentType) != MIMEOctetStream {
+		t.Fatalf("unexpected file parts %v", files)
+	}
+	f, _ := files[0].Open()
+	defer f.Close()
+	if content, _ := io.ReadAll(f); string(content) != "hello" {
+		t.Errorf("file content = %q, want hello", content)
+	}
+
+	if _, _, err = encodeRequestBody(MIMEMultipartForm, map[string]any{"files": []any{map[string]any{"content": "%%"}}}); err == nil {
+		t.Errorf("invalid base64 content should fail")
+	}
+}
+
+func TestClient_sendBody(t *testing.T) {
+	var gotBody, gotContentType string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		gotBody, gotContentType = string(b), r.Header.Get(HeaderContentType)
+	}))
+	defer server.Close()
+
+	client := (&Client{}).Instance().(*Client)
+	handler := func(ctx context.Context, port string, data any) error {
+		return nil
+	}
+
+	tests := []struct {
+		name            string
+		headers         []Header
+		wantContentType string
+	}{
+		{name: "content type from settings", wantContentType: MIMEApplicationJSON},
+		{name: "content type overridden by headers", headers: []Header{{Key: HeaderContentType, Value: "application/vnd.api+json"}}, wantContentType: "application/vnd.api+json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := client.Handle(context.Background(), handler, ClientRequestPort, ClientRequest{
+				Request: ClientRequestRequest{
+					Method:      http.MethodPost,
+					URL:         server.URL,
+					ContentType: MIMEApplicationJSON,
+					Headers:     tt.headers,
+					Body:        map[string]any{"name": "bob"},
+				},
+			})
+			if err != nil {
+				t.Fatalf("Handle() error = %v", err)
+			}
+			if gotBody != `{"name":"bob"}` || gotContentType != tt.wantContentType {
+				t.Errorf("sent body = %q, content type = %q", gotBody, gotContentType)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/clbanning/mxj/v2"
+	"github.com/spyzhov/ajson"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// errResponseTooLarge is not retried, the same response is expected on every attempt
+var errResponseTooLarge = errors.New("response body is too large")
+
+type ClientExtraction struct {
+	Name string `json:"name" required:"true" title:"Field name" minLength:"1" colSpan:"col-span-4"`
+	Path string `json:"path" required:"true" title:"Path" description:"JSONPath starting with $, e.g. $.data.items[0].id, or element path for XML and JSON documents, e.g. /feed/entry/title or feed.entry.title" minLength:"1" colSpan:"col-span-6"`
+	All  bool   `json:"all" title:"All matches" description:"Return array of all matches instead of the first one" colSpan:"col-span-2"`
+}
+
+// extractFields evaluates extractions against the response, raw is JSON document when body was decoded from JSON
+func extractFields(extractions []ClientExtraction, raw []byte, isJSON bool, body any) (map[string]any, error) {
+	if len(extractions) == 0 {
+		return nil, nil
+	}
+
+	fields := make(map[string]any, len(extractions))
+	for _, e := range extractions {
+		var (
+			values []any
+			err    error
+		)
+		if strings.HasPrefix(e.Path, "$") {
+			if !isJSON {
+				return nil, fmt.Errorf("field %s: JSONPath requires JSON response", e.Name)
+			}
+			values, err = jsonPathValues(raw, e.Path)
+		} else {
+			values, err = elementPathValues(body, e.Path)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %v", e.Name, err)
+		}
+
+		switch {
+		case e.All:
+			fields[e.Name] = values
+		case len(values) > 0:
+			fields[e.Name] = values[0]
+		default:
+			fields[e.Name] = nil
+		}
+	}
+	return fields, nil
+}
+
+func jsonPathValues(raw []byte, path string) ([]any, error) {
+	nodes, err := ajson.JSONPath(raw, path)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]any, 0, len(nodes))
+	for _, node := range nodes {
+		v, err := node.Unpack()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// elementPathValues finds values by slash or dot separated element names, arrays on the way are expanded
+func elementPathValues(body any, path string) ([]any, error) {
+	m, ok := body.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("element path requires XML or JSON object response")
+	}
+	path = strings.ReplaceAll(strings.Trim(path, "/"), "/", ".")
+	values, err := mxj.Map(m).ValuesForPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if values == nil {
+		values = make([]any, 0)
+	}
+	return values, nil
+}
+
+// readBody reads response body up to maxSize bytes, binary bodies are base64 encoded while reading
+func readBody(resp *http.Response, overrideContentType ContentType, maxSize int64) ([]byte, bool, error) {
+	var (
+		r       io.Reader = resp.Body
+		limited *io.LimitedReader
+	)
+	if maxSize > 0 {
+		limited = &io.LimitedReader{R: r, N: maxSize + 1}
+		r = limited
+	}
+
+	br := bufio.NewReader(r)
+
+	contentType := string(overrideContentType)
+	if contentType == "" {
+		contentType = resp.Header.Get(HeaderContentType)
+	}
+	if contentType == "" {
+		// sniff what server did not tell
+		head, _ := br.Peek(512)
+		contentType = http.DetectContentType(head)
+	}
+
+	encoded := !isTextContentType(contentType)
+
+	buf := &bytes.Buffer{}
+	var err error
+	if encoded {
+		w := base64.NewEncoder(base64.StdEncoding, buf)
+		if _, err = io.Copy(w, br); err == nil {
+			err = w.Close()
+		}
+	} else {
+		_, err = io.Copy(buf, br)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if limited != nil && limited.N == 0 {
+		return nil, false, fmt.Errorf("%w: limit is %d bytes", errResponseTooLarge, maxSize)
+	}
+	return buf.Bytes(), encoded, nil
+}
+
+func isTextContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.TrimSpace(strings.Split(contentType, ";")[0])
+	}
+	mediaType = strings.ToLower(mediaType)
+
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "+json"),
+		strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+	switch mediaType {
+	case MIMEApplicationJSON, MIMEApplicationXML, MIMEApplicationForm,
+		"application/javascript", "application/ecmascript", "application/x-ndjson",
+		"application/yaml", "application/x-yaml", "application/graphql":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,200 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_extractFields(t *testing.T) {
+	jsonRaw := []byte(`{"data":{"items":[{"id":1},{"id":2}],"total":2}}`)
+	jsonBody := map[string]any{
+		"data": map[string]any{
+			"items": []any{map[string]any{"id": float64(1)}, map[string]any{"id": float64(2)}},
+			"total": float64(2),
+		},
+	}
+	xmlBody := map[string]any{
+		"feed": map[string]any{
+			"entry": []any{map[string]any{"title": "a"}, map[string]any{"title": "b"}},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		extractions []ClientExtraction
+		raw         []byte
+		isJSON      bool
+		body        any
+		want        map[string]any
+		wantErr     bool
+	}{
+		{name: "none"},
+		{
+			name:        "json path",
+			extractions: []ClientExtraction{{Name: "total", Path: "$.data.total"}, {Name: "ids", Path: "$.data.items[*].id", All: true}, {Name: "missing", Path: "$.nope"}},
+			raw:         jsonRaw,
+			isJSON:      true,
+			body:        jsonBody,
+			want:        map[string]any{"total": float64(2), "ids": []any{float64(1), float64(2)}, "missing": nil},
+		},
+		{
+			name:        "element path in json",
+			extractions: []ClientExtraction{{Name: "first", Path: "data.items.id"}},
+			raw:         jsonRaw,
+			isJSON:      true,
+			body:        jsonBody,
+			want:        map[string]any{"first": float64(1)},
+		},
+		{
+			name:        "element path in xml",
+			extractions: []ClientExtraction{{Name: "titles", Path: "/feed/entry/title", All: true}, {Name: "none", Path: "/feed/author", All: true}},
+			body:        xmlBody,
+			want:        map[string]any{"titles": []any{"a", "b"}, "none": []any{}},
+		},
+		{
+			name:        "json path in xml",
+			extractions: []ClientExtraction{{Name: "title", Path: "$.feed"}},
+			body:        xmlBody,
+			wantErr:     true,
+		},
+		{
+			name:        "element path in text",
+			extractions: []ClientExtraction{{Name: "title", Path: "feed"}},
+			body:        "text",
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := extractFields(tt.extractions, tt.raw, tt.isJSON, tt.body)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("extractFields() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("extractFields() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_readBody(t *testing.T) {
+	binary := []byte{0x89, 'P', 'N', 'G', 0, 1, 2, 3}
+
+	tests := []struct {
+		name        string
+		contentType string
+		override    ContentType
+		body        []byte
+		maxSize     int64
+		want        string
+		wantEncoded bool
+		wantErr     error
+	}{
+		{name: "text", contentType: MimeTextPlain, body: []byte("hello"), want: "hello"},
+		{name: "vendor json", contentType: "application/vnd.api+json", body: []byte(`{}`), want: `{}`},
+		{name: "binary", contentType: "image/png", body: binary, want: base64.StdEncoding.EncodeToString(binary), wantEncoded: true},
+		{name: "sniffed binary", body: binary, want: base64.StdEncoding.EncodeToString(binary), wantEncoded: true},
+		{name: "sniffed text", body: []byte("hello"), want: "hello"},
+		{name: "override", contentType: MIMEOctetStream, override: MIMEApplicationJSON, body: []byte(`{}`), want: `{}`},
+		{name: "within limit", contentType: MimeTextPlain, body: []byte("hello"), maxSize: 5, want: "hello"},
+		{name: "over limit", contentType: MimeTextPlain, body: []byte("hello!"), maxSize: 5, wantErr: errResponseTooLarge},
+		{name: "binary over limit", contentType: "image/png", body: binary, maxSize: 4, wantErr: errResponseTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{}, Body: io.NopCloser(bytes.NewReader(tt.body))}
+			if tt.contentType != "" {
+				resp.Header.Set(HeaderContentType, tt.contentType)
+			}
+
+			got, encoded, err := readBody(resp, tt.override, tt.maxSize)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("readBody() error = %v, want %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want || encoded != tt.wantEncoded {
+				t.Errorf("readBody() = %q, %v, want %q, %v", got, encoded, tt.want, tt.wantEncoded)
+			}
+		})
+	}
+}
+
+func TestClient_responseSizeLimit(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		_, _ = w.Write([]byte(strings.Repeat("x", 100)))
+	}))
+	defer server.Close()
+
+	client := (&Client{}).Instance().(*Client)
+	handler := func(ctx context.Context, port string, data any) error {
+		return nil
+	}
+
+	err := client.Handle(context.Background(), handler, ClientRequestPort, ClientRequest{
+		Request: ClientRequestRequest{
+			Method:          http.MethodGet,
+			URL:             server.URL,
+			MaxResponseSize: 10,
+			Retry:           ClientRetry{MaxAttempts: 3, BackoffBase: 1},
+		},
+	})
+	if !errors.Is(err, errResponseTooLarge) {
+		t.Fatalf("Handle() error = %v, want response too large", err)
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, too large response should not be retried", calls)
+	}
+}
+
+func Test_decodeResponse(t *testing.T) {
+	binary := &clientResponse{
+		statusCode: http.StatusOK,
+		header:     http.Header{HeaderContentType: []string{"image/png"}},
+		body:       []byte(base64.StdEncoding.EncodeToString([]byte{0x89, 'P', 'N', 'G'})),
+		encoded:    true,
+	}
+	jsonResponse := &clientResponse{
+		statusCode: http.StatusOK,
+		header:     http.Header{HeaderContentType: []string{MIMEApplicationJSON}},
+		body:       []byte(`{"id":7}`),
+	}
+	extract := []ClientExtraction{{Name: "id", Path: "$.id"}}
+
+	tests := []struct {
+		name       string
+		resp       *clientResponse
+		extract    []ClientExtraction
+		wantErr    bool
+		wantFields map[string]any
+	}{
+		{name: "binary", resp: binary},
+		{name: "binary with extractions", resp: binary, extract: extract, wantErr: true},
+		{name: "json with extractions", resp: jsonResponse, extract: extract, wantFields: map[string]any{"id": float64(7)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeResponse(tt.resp, ClientRequestRequest{Extract: tt.extract})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeResponse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(got.Fields, tt.wantFields) {
+				t.Errorf("fields = %v, want %v", got.Fields, tt.wantFields)
+			}
+			if got.Base64 != tt.resp.encoded {
+				t.Errorf("base64 = %v, want %v", got.Base64, tt.resp.encoded)
+			}
+		})
+	}
+}
@@ -0,0 +1,393 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/spyzhov/ajson"
+	"github.com/tiny-systems/module/module"
+	"github.com/tiny-systems/module/registry"
+	"net/http"
+	"strings"
+)
+
+const (
+	GraphQLClientComponent = "graphql_client"
+	GraphQLRequestPort     = "request"
+	GraphQLResponsePort    = "response"
+	GraphQLErrorPort       = "error"
+)
+
+type GraphQLContext any
+
+type GraphQLClientSettings struct {
+	EnableErrorPort bool                 `json:"enableErrorPort" required:"true" title:"Enable Error Port" description:"If request may fail or result has errors, error port will emit an error message"`
+	CircuitBreaker  ClientCircuitBreaker `json:"circuitBreaker" title:"Circuit breaker" description:"Stop calling hosts which keep failing"`
+	Transport       ClientTransport      `json:"transport" title:"Transport" description:"Connection, proxy, TLS and redirect options shared by all requests"`
+}
+
+type GraphQLRequest struct {
+	Context GraphQLContext        `json:"context,omitempty" configurable:"true" title:"Context" description:"Message to be sent further"`
+	Request GraphQLRequestRequest `json:"request" title:"Request" required:"true" description:"GraphQL Request"`
+}
+
+type GraphQLRequestRequest struct {
+	URL     string `json:"url" required:"true" title:"URL" format:"uri" colSpan:"col-span-8"`
+	Timeout int    `json:"timeout" required:"true" title:"Request Timeout" colSpan:"col-span-4"`
+
+	Query         string         `json:"query" required:"true" title:"Query" format:"textarea"`
+	Variables     map[string]any `json:"variables" configurable:"true" title:"Variables"`
+	OperationName string         `json:"operationName,omitempty" title:"Operation name" description:"Operation to execute when query contains several"`
+
+	Headers         []Header          `json:"headers" required:"true" title:"Headers"`
+	Auth            ClientAuth        `json:"auth" title:"Auth" description:"Credentials added to the request"`
+	Retry           ClientRetry       `json:"retry" title:"Retry" description:"Retry policy for failed requests"`
+	MaxResponseSize int64             `json:"maxResponseSize" title:"Max response size" description:"Bytes, zero means unlimited" minimum:"0"`
+	Pagination      GraphQLPagination `json:"pagination" title:"Pagination" description:"Follow cursor based connection pages"`
+}
+
+type GraphQLPagination struct {
+	Enable         bool   `json:"enable" title:"Enable pagination"`
+	ConnectionPath string `json:"connectionPath" title:"Connection JSONPath" description:"Connection in the response, e.g. $.data.repository.issues. Query should select pageInfo { hasNextPage endCursor } and nodes or edges { node } of it"`
+	CursorVariable string `json:"cursorVariable" title:"Cursor variable" description:"Query variable the end cursor is passed in, e.g. after" colSpan:"col-span-4"`
+	MaxPages       int    `json:"maxPages" title:"Max pages" minimum:"1" default:"10" colSpan:"col-span-4"`
+	Emit           string `json:"emit" title:"Emit" enum:"page,combined" enumTitles:"Message per page,Combined nodes" default:"page" colSpan:"col-span-4"`
+}
+
+type GraphQLResponse struct {
+	Context  GraphQLContext        `json:"context" configurable:"true" required:"true" title:"Context" description:"Message to be sent further"`
+	Request  GraphQLRequestRequest `json:"request" title:"Request" required:"true" description:"GraphQL Request"`
+	Data     any                   `json:"data" required:"true" title:"Data" description:"Data of the result, nodes of all pages for combined pagination"`
+	Page     int                   `json:"page" title:"Page" description:"Page number starting with 1, number of pages for combined nodes"`
+	Attempts int                   `json:"attempts" title:"Attempts" description:"Number of attempts made"`
+}
+
+type GraphQLError struct {
+	Context    GraphQLContext        `json:"context" configurable:"true" required:"true" title:"Context" description:"Message to be sent further"`
+	Request    GraphQLRequestRequest `json:"request" required:"true" title:"Request"`
+	Data       any                   `json:"data" title:"Data" description:"Partial data returned along with errors"`
+	Errors     []any                 `json:"errors" title:"Errors" description:"Errors of the result"`
+	Error      string                `json:"error" required:"true" title:"Error"`
+	StatusCode int                   `json:"statusCode" title:"Status code"`
+}
+
+type GraphQLClient struct {
+	settings GraphQLClientSettings
+	// client sends requests sharing its transport, breaker and token cache
+	client *Client
+}
+
+type graphQLPayload struct {
+	Query         string         `json:"query"`
+	Variables     map[string]any `json:"variables,omitempty"`
+	OperationName string         `json:"operationName,omitempty"`
+}
+
+type graphQLResult struct {
+	Data   any   `json:"data"`
+	Errors []any `json:"errors"`
+}
+
+func (g *GraphQLClient) Instance() module.Component {
+	client := (&Client{}).Instance().(*Client)
+	return &GraphQLClient{
+		settings: GraphQLClientSettings{
+			CircuitBreaker: client.settings.CircuitBreaker,
+			Transport:      client.settings.Transport,
+		},
+		client: client,
+	}
+}
+
+func (g *GraphQLClient) GetInfo() module.ComponentInfo {
+	return module.ComponentInfo{
+		Name:        GraphQLClientComponent,
+		Description: "GraphQL Client",
+		Info:        "Performs GraphQL queries and mutations. Data of the result goes to the response port, errors go to the error port if it's enabled.",
+		Tags:        []string{"HTTP", "Client", "GraphQL"},
+	}
+}
+
+func (g *GraphQLClient) Handle(ctx context.Context, handler module.Handler, port string, msg interface{}) error {
+	switch port {
+	case module.SettingsPort:
+		in, ok := msg.(GraphQLClientSettings)
+		if !ok {
+			return fmt.Errorf("invalid settings")
+		}
+		if err := g.client.Handle(ctx, handler, module.SettingsPort, ClientRequestSettings{
+			EnableErrorPort: in.EnableErrorPort,
+			CircuitBreaker:  in.CircuitBreaker,
+			Transport:       in.Transport,
+		}); err != nil {
+			return err
+		}
+		g.settings = in
+		return nil
+
+	case GraphQLRequestPort:
+		in, ok := msg.(GraphQLRequest)
+		if !ok {
+			return fmt.Errorf("invalid message")
+		}
+		request := in.Request
+		// credentials are not passed further
+		in.Request.Auth = in.Request.Auth.redacted()
+
+		pagination := request.Pagination
+		if pagination.Enable && (pagination.ConnectionPath == "" || pagination.CursorVariable == "") {
+			return g.sendError(ctx, handler, in, GraphQLError{Error: "pagination requires connection JSONPath and cursor variable"})
+		}
+
+		variables := make(map[string]any, len(request.Variables)+1)
+		for k, v := range request.Variables {
+			variables[k] = v
+		}
+
+		var (
+			data          any
+			page          int
+			totalAttempts int
+			combined      = make([]any, 0)
+		)
+
+		for page = 1; ; page++ {
+			in.Request.Variables = variables
+
+			body, err := json.Marshal(graphQLPayload{
+				Query:         request.Query,
+				Variables:     variables,
+				OperationName: request.OperationName,
+			})
+			if err != nil {
+				return g.sendError(ctx, handler, in, GraphQLError{Error: err.Error()})
+			}
+
+			resp, attempts, err := g.client.send(ctx, request.clientRequest(), body, MIMEApplicationJSON)
+			totalAttempts += attempts
+			if err != nil {
+				return g.sendError(ctx, handler, in, GraphQLError{Error: err.Error()})
+			}
+
+			var result graphQLResult
+			if err = json.Unmarshal(resp.body, &result); err != nil {
+				return g.sendError(ctx, handler, in, GraphQLError{
+					Error:      fmt.Sprintf("invalid GraphQL response: %s", resp.status),
+					StatusCode: resp.statusCode,
+				})
+			}
+			if len(result.Errors) > 0 || resp.statusCode >= 400 {
+				return g.sendError(ctx, handler, in, GraphQLError{
+					Data:       result.Data,
+					Errors:     result.Errors,
+					Error:      graphQLErrorMessage(result.Errors, resp.status),
+					StatusCode: resp.statusCode,
+				})
+			}
+			data = result.Data
+
+			if !pagination.Enable {
+				break
+			}
+
+			nodes, cursor, hasNext, err := pagination.connection(resp.body)
+			if err != nil {
+				return g.sendError(ctx, handler, in, GraphQLError{Data: data, Error: err.Error(), StatusCode: resp.statusCode})
+			}
+
+			if pagination.Emit == PaginationEmitCombined {
+				combined = append(combined, nodes...)
+			} else if err = handler(ctx, GraphQLResponsePort, GraphQLResponse{
+				Context:  in.Context,
+				Request:  in.Request,
+				Data:     data,
+				Page:     page,
+				Attempts: attempts,
+			}); err != nil {
+				return err
+			}
+
+			if !hasNext || cursor == "" || page >= pagination.maxPages() {
+				break
+			}
+
+			next := make(map[string]any, len(variables))
+			for k, v := range variables {
+				next[k] = v
+			}
+			next[pagination.CursorVariable] = cursor
+			variables = next
+		}
+
+		if pagination.Enable && pagination.Emit != PaginationEmitCombined {
+			return nil
+		}
+		if pagination.Enable {
+			data = combined
+		}
+
+		return handler(ctx, GraphQLResponsePort, GraphQLResponse{
+			Context:  in.Context,
+			Request:  in.Request,
+			Data:     data,
+			Page:     page,
+			Attempts: totalAttempts,
+		})
+
+	default:
+		return fmt.Errorf("port %s is not supoprted", port)
+	}
+}
+
+// sendError emits error to the error port if it's enabled, returns the error otherwise
+func (g *GraphQLClient) sendError(ctx context.Context, handler module.Handler, in GraphQLRequest, e GraphQLError) error {
+	// inner client keeps settings under its lock
+	if !g.client.getSettings().EnableErrorPort {
+		return errors.New(e.Error)
+	}
+	e.Context = in.Context
+	e.Request = in.Request
+	return handler(ctx, GraphQLErrorPort, e)
+}
+
+// clientRequest is HTTP request carrying GraphQL payload
+func (r GraphQLRequestRequest) clientRequest() ClientRequestRequest {
+	return ClientRequestRequest{
+		Method:              http.MethodPost,
+		URL:                 r.URL,
+		Timeout:             r.Timeout,
+		ContentType:         MIMEApplicationJSON,
+		Headers:             r.Headers,
+		ResponseContentType: MIMEApplicationJSON,
+		Retry:               r.Retry,
+		Auth:                r.Auth,
+		MaxResponseSize:     r.MaxResponseSize,
+	}
+}
+
+func (p GraphQLPagination) maxPages() int {
+	if p.MaxPages < 1 {
+		return defaultMaxPages
+	}
+	return p.MaxPages
+}
+
+// connection reads nodes and page info of the connection
+func (p GraphQLPagination) connection(raw []byte) ([]any, string, bool, error) {
+	nodes, err := ajson.JSONPath(raw, p.ConnectionPath)
+	if err != nil {
+		return nil, "", false, fmt.Errorf("unable to find connection: %v", err)
+	}
+	if len(nodes) == 0 || !nodes[0].IsObject() {
+		return nil, "", false, fmt.Errorf("connection %s not found in response", p.ConnectionPath)
+	}
+	v, err := nodes[0].Unpack()
+	if err != nil {
+		return nil, "", false, err
+	}
+	conn, _ := v.(map[string]any)
+
+	var items []any
+	if list, ok := conn["nodes"].([]any); ok {
+		items = list
+	} else if edges, ok := conn["edges"].([]any); ok {
+		for _, edge := range edges {
+			if e, ok := edge.(map[string]any); ok {
+				items = append(items, e["node"])
+			}
+		}
+	}
+
+	pageInfo, ok := conn["pageInfo"].(map[string]any)
+	if !ok {
+		return nil, "", false, fmt.Errorf("connection %s has no pageInfo", p.ConnectionPath)
+	}
+	hasNext, _ := pageInfo["hasNextPage"].(bool)
+	cursor, _ := pageInfo["endCursor"].(string)
+	return items, cursor, hasNext, nil
+}
+
+// graphQLErrorMessage joins messages of the result errors
+func graphQLErrorMessage(errs []any, status string) string {
+	if len(errs) == 0 {
+		return status
+	}
+	messages := make([]string, 0, len(errs))
+	for _, e := range errs {
+		if m, ok := e.(map[string]any); ok && m["message"] != nil {
+			messages = append(messages, fmt.Sprint(m["message"]))
+			continue
+		}
+		messages = append(messages, fmt.Sprint(e))
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (g *GraphQLClient) Ports() []module.Port {
+	ports := []module.Port{
+		{
+			Name:   GraphQLRequestPort,
+			Label:  "Request",
+			Source: true,
+			Configuration: GraphQLRequest{
+				Request: GraphQLRequestRequest{
+					URL:       "http://example.com/graphql",
+					Timeout:   10,
+					Query:     "query { __typename }",
+					Variables: make(map[string]any),
+					Headers:   make([]Header, 0),
+					Auth: ClientAuth{
+						Type:       ClientAuthNone,
+						APIKeyName: "X-API-Key",
+						APIKeyIn:   APIKeyInHeader,
+					},
+					MaxResponseSize: 10 << 20,
+					Retry: ClientRetry{
+						MaxAttempts:       1,
+						BackoffBase:       200,
+						BackoffMax:        10000,
+						Jitter:            true,
+						RetryStatusCodes:  []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+						RespectRetryAfter: true,
+					},
+					Pagination: GraphQLPagination{
+						CursorVariable: "after",
+						MaxPages:       defaultMaxPages,
+						Emit:           PaginationEmitPage,
+					},
+				},
+			},
+			Position: module.Left,
+		},
+		{
+			Name:          GraphQLResponsePort,
+			Label:         "Response",
+			Position:      module.Right,
+			Configuration: GraphQLResponse{},
+		},
+		{
+			Name:          module.SettingsPort,
+			Label:         "Settings",
+			Configuration: g.settings,
+			Source:        true,
+		},
+	}
+
+	if !g.settings.EnableErrorPort {
+		return ports
+	}
+
+	return append(ports, module.Port{
+		Name:          GraphQLErrorPort,
+		Label:         "Error",
+		Position:      module.Bottom,
+		Configuration: GraphQLError{},
+	})
+}
+
+var _ module.Component = (*GraphQLClient)(nil)
+
+func init() {
+	registry.Register(&GraphQLClient{})
+}
@@ -0,0 +1,142 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/tiny-systems/module/module"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestGraphQLClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload graphQLPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || r.Header.Get(HeaderContentType) != MIMEApplicationJSON {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set(HeaderContentType, MIMEApplicationJSON)
+
+		switch payload.OperationName {
+		case "fail":
+			_, _ = w.Write([]byte(`{"data":{"user":null},"errors":[{"message":"not found"},{"message":"denied"}]}`))
+		case "users":
+			// 3 users served by 2 per page
+			after, _ := payload.Variables["after"].(string)
+			switch after {
+			case "":
+				_, _ = w.Write([]byte(`{"data":{"users":{"nodes":[{"id":1},{"id":2}],"pageInfo":{"hasNextPage":true,"endCursor":"c2"}}}}`))
+			case "c2":
+				_, _ = w.Write([]byte(`{"data":{"users":{"edges":[{"node":{"id":3}}],"pageInfo":{"hasNextPage":false,"endCursor":"c3"}}}}`))
+			default:
+				w.WriteHeader(http.StatusBadRequest)
+			}
+		default:
+			_, _ = fmt.Fprintf(w, `{"data":{"echo":%q}}`, payload.Variables["name"])
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		errorPort  bool
+		request    GraphQLRequestRequest
+		wantErr    bool
+		wantPort   []string
+		wantData   any
+		wantPages  []int
+		wantErrors int
+	}{
+		{
+			name:      "query",
+			request:   GraphQLRequestRequest{Query: "query($name: String) { echo(name: $name) }", Variables: map[string]any{"name": "bob"}},
+			wantPort:  []string{GraphQLResponsePort},
+			wantData:  map[string]any{"echo": "bob"},
+			wantPages: []int{1},
+		},
+		{
+			name:       "errors",
+			errorPort:  true,
+			request:    GraphQLRequestRequest{Query: "query fail { user { id } }", OperationName: "fail"},
+			wantPort:   []string{GraphQLErrorPort},
+			wantData:   map[string]any{"user": nil},
+			wantErrors: 2,
+		},
+		{
+			name:    "errors without error port",
+			request: GraphQLRequestRequest{Query: "query fail { user { id } }", OperationName: "fail"},
+			wantErr: true,
+		},
+		{
+			name: "pages",
+			request: GraphQLRequestRequest{Query: "query users($after: String) { users(after: $after) { nodes { id } } }", OperationName: "users",
+				Pagination: GraphQLPagination{Enable: true, ConnectionPath: "$.data.users", CursorVariable: "after"}},
+			wantPort:  []string{GraphQLResponsePort, GraphQLResponsePort},
+			wantPages: []int{1, 2},
+		},
+		{
+			name: "combined",
+			request: GraphQLRequestRequest{Query: "query users($after: String) { users(after: $after) { nodes { id } } }", OperationName: "users",
+				Pagination: GraphQLPagination{Enable: true, ConnectionPath: "$.data.users", CursorVariable: "after", Emit: PaginationEmitCombined}},
+			wantPort:  []string{GraphQLResponsePort},
+			wantData:  []any{map[string]any{"id": float64(1)}, map[string]any{"id": float64(2)}, map[string]any{"id": float64(3)}},
+			wantPages: []int{2},
+		},
+		{
+			name: "combined max pages",
+			request: GraphQLRequestRequest{Query: "query users($after: String) { users(after: $after) { nodes { id } } }", OperationName: "users",
+				Pagination: GraphQLPagination{Enable: true, ConnectionPath: "$.data.users", CursorVariable: "after", Emit: PaginationEmitCombined, MaxPages: 1}},
+			wantPort:  []string{GraphQLResponsePort},
+			wantData:  []any{map[string]any{"id": float64(1)}, map[string]any{"id": float64(2)}},
+			wantPages: []int{1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := (&GraphQLClient{}).Instance().(*GraphQLClient)
+			if err := client.Handle(context.Background(), nil, module.SettingsPort, GraphQLClientSettings{EnableErrorPort: tt.errorPort}); err != nil {
+				t.Fatal(err)
+			}
+
+			var (
+				ports     []string
+				pages     []int
+				data      any
+				errorsLen int
+			)
+			handler := func(ctx context.Context, port string, msg any) error {
+				ports = append(ports, port)
+				switch m := msg.(type) {
+				case GraphQLResponse:
+					pages = append(pages, m.Page)
+					data = m.Data
+				case GraphQLError:
+					data = m.Data
+					errorsLen = len(m.Errors)
+				}
+				return nil
+			}
+
+			tt.request.URL = server.URL
+			err := client.Handle(context.Background(), handler, GraphQLRequestPort, GraphQLRequest{Request: tt.request})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Handle() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(ports, tt.wantPort) {
+				t.Fatalf("ports = %v, want %v", ports, tt.wantPort)
+			}
+			if tt.wantData != nil && !reflect.DeepEqual(data, tt.wantData) {
+				t.Errorf("data = %#v, want %#v", data, tt.wantData)
+			}
+			if tt.wantPages != nil && !reflect.DeepEqual(pages, tt.wantPages) {
+				t.Errorf("pages = %v, want %v", pages, tt.wantPages)
+			}
+			if errorsLen != tt.wantErrors {
+				t.Errorf("errors = %d, want %d", errorsLen, tt.wantErrors)
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package http
+
+import (
+	"context"
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+	"github.com/tiny-systems/module/module"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// latency samples kept per metrics interval
+const maxLatencySamples = 10000
+
+// echo context key marking requests which were not answered in time
+const timedOutKey = "timedOut"
+
+// access log entries waiting for the flow, entries above it are dropped instead of slowing down responses
+const accessLogQueueSize = 1000
+
+type ServerAccessLog struct {
+	Context      ServerStartContext `json:"context"`
+	Method       string             `json:"method" title:"Method"`
+	RequestURI   string             `json:"requestURI" title:"Request URI"`
+	RealIP       string             `json:"realIP" title:"Real IP"`
+	StatusCode   int                `json:"statusCode" title:"Status code"`
+	Latency      float64            `json:"latency" title:"Latency" description:"Milliseconds"`
+	BytesWritten int64              `json:"bytesWritten" title:"Bytes written"`
+	TimedOut     bool               `json:"timedOut" title:"Timed out"`
+}
+
+type ServerMetrics struct {
+	RequestsPerSecond float64 `json:"requestsPerSecond" title:"Requests per second" readonly:"true"`
+	LatencyP50        float64 `json:"latencyP50" title:"Latency p50, ms" readonly:"true"`
+	LatencyP95        float64 `json:"latencyP95" title:"Latency p95, ms" readonly:"true"`
+	Status2xx         int64   `json:"status2xx" title:"2xx" readonly:"true"`
+	Status3xx         int64   `json:"status3xx" title:"3xx" readonly:"true"`
+	Status4xx         int64   `json:"status4xx" title:"4xx" readonly:"true"`
+	Status5xx         int64   `json:"status5xx" title:"5xx" readonly:"true"`
+	TimedOut          int64   `json:"timedOut" title:"Timed out" readonly:"true"`
+}
+
+// metricsCollector aggregates finished requests between flushes
+type metricsCollector struct {
+	lock      *sync.Mutex
+	since     time.Time
+	requests  int64
+	latencies []float64
+	current   ServerMetrics
+	last      ServerMetrics
+}
+
+func newMetricsCollector() *metricsCollector {
+	return &metricsCollector{
+		lock:  &sync.Mutex{},
+		since: time.Now(),
+	}
+}
+
+func (m *metricsCollector) observe(statusCode int, latency time.Duration, timedOut bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.requests++
+	if len(m.latencies) < maxLatencySamples {
+		m.latencies = append(m.latencies, float64(latency.Microseconds())/1000)
+	}
+
+	switch {
+	case statusCode >= 500:
+		m.current.Status5xx++
+	case statusCode >= 400:
+		m.current.Status4xx++
+	case statusCode >= 300:
+		m.current.Status3xx++
+	case statusCode >= 200:
+		m.current.Status2xx++
+	}
+	if timedOut {
+		m.current.TimedOut++
+	}
+}
+
+// flush calculates aggregates of the passed interval and starts a new one
+func (m *metricsCollector) flush() ServerMetrics {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	result := m.current
+	if elapsed := time.Since(m.since).Seconds(); elapsed > 0 {
+		result.RequestsPerSecond = math.Round(float64(m.requests)/elapsed*100) / 100
+	}
+
+	sort.Float64s(m.latencies)
+	result.LatencyP50 = percentile(m.latencies, 0.5)
+	result.LatencyP95 = percentile(m.latencies, 0.95)
+
+	m.last = result
+	m.current = ServerMetrics{}
+	m.requests = 0
+	m.latencies = m.latencies[:0]
+	m.since = time.Now()
+
+	return result
+}
+
+func (m *metricsCollector) reset() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.last = ServerMetrics{}
+	m.current = ServerMetrics{}
+	m.requests = 0
+	m.latencies = m.latencies[:0]
+	m.since = time.Now()
+}
+
+func (m *metricsCollector) getLast() ServerMetrics {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.last
+}
+
+// percentile of sorted values using nearest rank
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	return sorted[idx]
+}
+
+// accessLogMiddleware observes every finished request and queues it for the access log port if enabled
+func (h *Server) accessLogMiddleware(start ServerStart, accessLog chan<- ServerAccessLog) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if websocket.IsWebSocketUpgrade(c.Request()) {
+				// connection lifetime is not a request latency
+				return next(c)
+			}
+
+			started := time.Now()
+			if err := next(c); err != nil {
+				// let error handler write the response so status code is known
+				c.Error(err)
+			}
+			latency := time.Since(started)
+
+			timedOut, _ := c.Get(timedOutKey).(bool)
+			h.metrics.observe(c.Response().Status, latency, timedOut)
+
+			if !h.getSettings().EnableAccessLogPort {
+				return nil
+			}
+
+			entry := ServerAccessLog{
+				Context:      start.Context,
+				Method:       c.Request().Method,
+				RequestURI:   c.Request().RequestURI,
+				RealIP:       c.RealIP(),
+				StatusCode:   c.Response().Status,
+				Latency:      float64(latency.Microseconds()) / 1000,
+				BytesWritten: c.Response().Size,
+				TimedOut:     timedOut,
+			}
+
+			// small responses may still sit in the buffer, deliver them before the access log flow runs
+			if c.Response().Committed {
+				_ = http.NewResponseController(c.Response().Writer).Flush()
+			}
+			select {
+			case accessLog <- entry:
+			default:
+			}
+			return nil
+		}
+	}
+}
+
+// sendAccessLog delivers queued entries one by one so they reach the flow in order, stops with the server
+func (h *Server) sendAccessLog(ctx context.Context, accessLog <-chan ServerAccessLog, handler module.Handler) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case entry := <-accessLog:
+			_ = handler(ctx, ServerAccessLogPort, entry)
+		}
+	}
+}
+
+// reportMetrics flushes aggregates every interval and redraws the component when they change
+func (h *Server) reportMetrics(ctx context.Context, interval time.Duration, handler module.Handler) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var last ServerMetrics
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			metrics := h.metrics.flush()
+			if metrics == last {
+				continue
+			}
+			last = metrics
+			_ = handler(ctx, module.ReconcilePort, nil)
+		}
+	}
+}
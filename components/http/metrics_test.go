@@ -0,0 +1,107 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"github.com/tiny-systems/module/module"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func Test_percentile(t *testing.T) {
+	tests := []struct {
+		name   string
+		sorted []float64
+		p      float64
+		want   float64
+	}{
+		{name: "empty", sorted: nil, p: 0.5, want: 0},
+		{name: "single", sorted: []float64{7}, p: 0.95, want: 7},
+		{name: "median of even", sorted: []float64{1, 2, 3, 4}, p: 0.5, want: 2},
+		{name: "median of odd", sorted: []float64{1, 2, 3, 4, 5}, p: 0.5, want: 3},
+		{name: "p95 of ten", sorted: []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, p: 0.95, want: 10},
+		{name: "zero rank", sorted: []float64{1, 2}, p: 0, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := percentile(tt.sorted, tt.p); got != tt.want {
+				t.Errorf("percentile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMetricsCollector_flush(t *testing.T) {
+	m := newMetricsCollector()
+
+	for _, status := range []int{200, 204, 301, 404, 429, 500, 503, 101} {
+		m.observe(status, time.Millisecond*10, false)
+	}
+	m.observe(http.StatusServiceUnavailable, time.Millisecond*100, true)
+	m.observe(http.StatusOK, time.Millisecond, false)
+
+	got := m.flush()
+
+	want := ServerMetrics{Status2xx: 3, Status3xx: 1, Status4xx: 2, Status5xx: 3, TimedOut: 1}
+	if got.Status2xx != want.Status2xx || got.Status3xx != want.Status3xx || got.Status4xx != want.Status4xx ||
+		got.Status5xx != want.Status5xx || got.TimedOut != want.TimedOut {
+		t.Errorf("flush() = %+v, want status classes %+v", got, want)
+	}
+	if got.LatencyP50 != 10 || got.LatencyP95 != 100 {
+		t.Errorf("latency p50 = %v, p95 = %v, want 10 and 100", got.LatencyP50, got.LatencyP95)
+	}
+	if got.RequestsPerSecond <= 0 {
+		t.Errorf("requests per second = %v, want positive rate", got.RequestsPerSecond)
+	}
+	if m.getLast() != got {
+		t.Errorf("getLast() = %+v, want flushed metrics", m.getLast())
+	}
+
+	// next interval starts empty
+	if next := m.flush(); next.Status2xx != 0 || next.LatencyP50 != 0 || next.RequestsPerSecond != 0 {
+		t.Errorf("flush() of empty interval = %+v, want zero metrics", next)
+	}
+}
+
+func TestServer_accessLog(t *testing.T) {
+	h := (&Server{}).Instance().(*Server)
+	settings := h.settings
+	settings.EnableAccessLogPort = true
+	if err := h.Handle(context.Background(), nil, module.SettingsPort, settings); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := make(chan ServerAccessLog, 20)
+
+	var handler module.Handler
+	handler = func(ctx context.Context, port string, data any) error {
+		switch port {
+		case ServerRequestPort:
+			req := data.(ServerRequest)
+			return h.Handle(ctx, handler, ServerResponsePort, ServerResponse{RequestID: req.RequestID, StatusCode: http.StatusOK, ContentType: MimeTextPlain})
+		case ServerAccessLogPort:
+			// slow flow must not reorder entries
+			time.Sleep(time.Millisecond * 20)
+			entries <- data.(ServerAccessLog)
+		}
+		return nil
+	}
+	url := startTestServer(t, h, testServerStart(), handler)
+
+	const requests = 5
+	for i := 0; i < requests; i++ {
+		resp, err := http.Get(fmt.Sprintf("%s/%d", url, i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	for i := 0; i < requests; i++ {
+		entry := receive(t, entries)
+		if want := fmt.Sprintf("/%d", i); entry.RequestURI != want || entry.StatusCode != http.StatusOK {
+			t.Errorf("entry %d = %s %d, want %s 200", i, entry.RequestURI, entry.StatusCode, want)
+		}
+	}
+}
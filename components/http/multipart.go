@@ -0,0 +1,133 @@
+package http
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+)
+
+// multipartMemory is the part of multipart form kept in memory while parsing, the rest is stored in temporary files
+const multipartMemory = 32 << 20
+
+type ServerMultipartBody struct {
+	Fields url.Values           `json:"fields" title:"Fields"`
+	Files  []ServerUploadedFile `json:"files" title:"Files"`
+}
+
+type ServerUploadedFile struct {
+	Field       string `json:"field" title:"Field name"`
+	Filename    string `json:"filename" title:"Filename"`
+	ContentType string `json:"contentType" title:"Content type"`
+	Size        int64  `json:"size" title:"Size"`
+	Content     string `json:"content,omitempty" title:"Content" description:"Base64 encoded file content"`
+	Path        string `json:"path,omitempty" title:"Path" description:"Temporary file with the content. Removed when the request is finished"`
+}
+
+// parseMultipart reads multipart form with uploaded files, returns list of temporary files to be removed after request is finished
+func parseMultipart(c echo.Context, maxSize int64, toTempFiles bool) (ServerMultipartBody, []string, error) {
+	req := c.Request()
+	if maxSize > 0 {
+		req.Body = http.MaxBytesReader(c.Response(), req.Body, maxSize)
+	}
+
+	body := ServerMultipartBody{
+		Fields: url.Values{},
+		Files:  make([]ServerUploadedFile, 0),
+	}
+
+	if err := req.ParseMultipartForm(multipartMemory); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return body, nil, echo.NewHTTPError(http.StatusRequestEntityTooLarge).SetInternal(err)
+		}
+		return body, nil, echo.NewHTTPError(http.StatusBadRequest, err.Error()).SetInternal(err)
+	}
+
+	form := req.MultipartForm
+	defer form.RemoveAll()
+
+	for k, v := range form.Value {
+		body.Fields[k] = v
+	}
+
+	fields := make([]string, 0, len(form.File))
+	for k := range form.File {
+		fields = append(fields, k)
+	}
+	sort.Strings(fields)
+
+	var tempFiles []string
+
+	for _, field := range fields {
+		for _, fh := range form.File[field] {
+			file := ServerUploadedFile{
+				Field:       field,
+				Filename:    fh.Filename,
+				ContentType: fh.Header.Get(HeaderContentType),
+				Size:        fh.Size,
+			}
+
+			var err error
+			if toTempFiles {
+				file.Path, err = copyToTempFile(fh)
+				if file.Path != "" {
+					tempFiles = append(tempFiles, file.Path)
+				}
+			} else {
+				file.Content, err = readBase64(fh)
+			}
+			if err != nil {
+				removeFiles(tempFiles)
+				return body, nil, fmt.Errorf("unable to read uploaded file %s: %v", fh.Filename, err)
+			}
+			body.Files = append(body.Files, file)
+		}
+	}
+	return body, tempFiles, nil
+}
+
+func readBase64(fh *multipart.FileHeader) (string, error) {
+	f, err := fh.Open()
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	b, err := io.ReadAll(f)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+func copyToTempFile(fh *multipart.FileHeader) (string, error) {
+	f, err := fh.Open()
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	tmp, err := os.CreateTemp("", "upload-*")
+	if err != nil {
+		return "", err
+	}
+	defer tmp.Close()
+
+	if _, err = io.Copy(tmp, f); err != nil {
+		return tmp.Name(), err
+	}
+	return tmp.Name(), nil
+}
+
+func removeFiles(paths []string) {
+	for _, p := range paths {
+		_ = os.Remove(p)
+	}
+}
@@ -0,0 +1,180 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"github.com/tiny-systems/module/module"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// multipartBody builds form with a field and a file
+func multipartBody(t *testing.T, content []byte) (*bytes.Buffer, string) {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	w := multipart.NewWriter(buf)
+	if err := w.WriteField("title", "report"); err != nil {
+		t.Fatal(err)
+	}
+	part, err := w.CreateFormFile("document", "report.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = part.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf, w.FormDataContentType()
+}
+
+func Test_parseMultipart(t *testing.T) {
+	content := []byte{0, 1, 2, 3, 4, 5, 6, 7}
+
+	tests := []struct {
+		name        string
+		maxSize     int64
+		toTempFiles bool
+		body        string
+		wantStatus  int
+	}{
+		{name: "base64 content"},
+		{name: "temporary files", toTempFiles: true},
+		{name: "within max size", maxSize: 10 << 10},
+		{name: "above max size", maxSize: 64, wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "malformed form", body: "--boundary\r\nbroken", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf, contentType := multipartBody(t, content)
+			if tt.body != "" {
+				buf = bytes.NewBufferString(tt.body)
+			}
+			req := httptest.NewRequest(http.MethodPost, "/", buf)
+			req.Header.Set(HeaderContentType, contentType)
+			c := echo.New().NewContext(req, httptest.NewRecorder())
+
+			body, tempFiles, err := parseMultipart(c, tt.maxSize, tt.toTempFiles)
+			defer removeFiles(tempFiles)
+
+			if tt.wantStatus != 0 {
+				var httpErr *echo.HTTPError
+				if !errors.As(err, &httpErr) || httpErr.Code != tt.wantStatus {
+					t.Fatalf("parseMultipart() error = %v, want status %d", err, tt.wantStatus)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(body.Fields["title"], []string{"report"}) {
+				t.Errorf("fields = %v, want title", body.Fields)
+			}
+			if len(body.Files) != 1 {
+				t.Fatalf("files = %d, want 1", len(body.Files))
+			}
+			file := body.Files[0]
+			if file.Field != "document" || file.Filename != "report.bin" || file.Size != int64(len(content)) {
+				t.Errorf("file = %+v, want document report.bin of %d bytes", file, len(content))
+			}
+
+			if !tt.toTempFiles {
+				if file.Content != base64.StdEncoding.EncodeToString(content) || file.Path != "" || len(tempFiles) != 0 {
+					t.Errorf("file = %+v, want base64 content only", file)
+				}
+				return
+			}
+
+			if file.Content != "" || !reflect.DeepEqual(tempFiles, []string{file.Path}) {
+				t.Fatalf("file = %+v, temp files %v, want path of temporary file only", file, tempFiles)
+			}
+			data, err := os.ReadFile(file.Path)
+			if err != nil || !bytes.Equal(data, content) {
+				t.Errorf("temporary file content = %v, %v, want %v", data, err, content)
+			}
+		})
+	}
+}
+
+func TestServer_uploads(t *testing.T) {
+	h := (&Server{}).Instance().(*Server)
+	settings := h.settings
+	settings.MaxUploadSize = 1 << 10
+	settings.UploadsToTempFiles = true
+	if err := h.Handle(context.Background(), nil, module.SettingsPort, settings); err != nil {
+		t.Fatal(err)
+	}
+
+	paths := make(chan string, 1)
+
+	var handler module.Handler
+	handler = func(ctx context.Context, port string, data any) error {
+		req, ok := data.(ServerRequest)
+		if port != ServerRequestPort || !ok {
+			return nil
+		}
+		body := req.Body.(ServerMultipartBody)
+
+		resp := ServerResponse{RequestID: req.RequestID, StatusCode: http.StatusOK, ContentType: MimeTextPlain}
+		for _, file := range body.Files {
+			// file is readable while the request is handled
+			if _, err := os.Stat(file.Path); err != nil {
+				resp.StatusCode = http.StatusInternalServerError
+			}
+			paths <- file.Path
+		}
+		return h.Handle(ctx, handler, ServerResponsePort, resp)
+	}
+	url := startTestServer(t, h, testServerStart(), handler)
+
+	t.Run("temporary files are removed", func(t *testing.T) {
+		buf, contentType := multipartBody(t, []byte("content"))
+		resp, err := http.Post(url, contentType, buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("status = %d, want 200", resp.StatusCode)
+		}
+
+		path := receive(t, paths)
+		deadline := time.Now().Add(time.Second * 2)
+		for {
+			if _, err = os.Stat(path); errors.Is(err, os.ErrNotExist) {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("temporary file %s is not removed", path)
+			}
+			time.Sleep(time.Millisecond * 20)
+		}
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		buf, contentType := multipartBody(t, []byte(strings.Repeat("a", 2<<10)))
+		resp, err := http.Post(url, contentType, buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusRequestEntityTooLarge {
+			t.Errorf("status = %d, want 413", resp.StatusCode)
+		}
+		if len(paths) != 0 {
+			t.Errorf("too large request should not reach the flow")
+		}
+	})
+}
@@ -0,0 +1,441 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/labstack/echo/v4"
+	"github.com/tiny-systems/module/pkg/schema"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const openAPIVersion = "3.0.3"
+
+type ServerOpenAPI struct {
+	Enable      bool   `json:"enable" title:"Enable OpenAPI" description:"Serve OpenAPI 3 document describing routes. Operations refer to request and response port messages in x-request-message and x-response-message"`
+	Path        string `json:"path" title:"Document path" default:"/openapi.json"`
+	DocsPath    string `json:"docsPath" title:"Docs page path" description:"Path of the page rendering the document, e.g. /docs. Leave empty to disable the page"`
+	Title       string `json:"title" title:"Title" default:"API"`
+	Version     string `json:"version" title:"Version" default:"1.0.0"`
+	Description string `json:"description" title:"Description" format:"textarea"`
+	Public      bool   `json:"public" title:"Public" description:"Serve document and docs page without authentication"`
+}
+
+func (o ServerOpenAPI) validate() error {
+	if !o.Enable {
+		return nil
+	}
+	if !strings.HasPrefix(o.Path, "/") {
+		return fmt.Errorf("openapi document path should start with /")
+	}
+	if o.DocsPath != "" && !strings.HasPrefix(o.DocsPath, "/") {
+		return fmt.Errorf("openapi docs page path should start with /")
+	}
+	if o.DocsPath == o.Path {
+		return fmt.Errorf("openapi docs page path should differ from document path")
+	}
+	return nil
+}
+
+// match checks if request should be answered with the document or docs page
+func (o ServerOpenAPI) match(req *http.Request) bool {
+	if !o.Enable || (req.Method != http.MethodGet && req.Method != http.MethodHead) {
+		return false
+	}
+	return req.URL.Path == o.Path || (o.DocsPath != "" && req.URL.Path == o.DocsPath)
+}
+
+func (h *Server) serveOpenAPI(c echo.Context, settings ServerSettings) error {
+	if c.Request().URL.Path == settings.OpenAPI.DocsPath {
+		return serveOpenAPIDocs(c, settings.OpenAPI)
+	}
+	doc, err := buildOpenAPI(settings, h.getSchemas(), h.getPublicListerAddr())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error()).SetInternal(err)
+	}
+	return c.JSON(http.StatusOK, doc)
+}
+
+// buildOpenAPI describes configured routes, their schemas and auth methods
+func buildOpenAPI(settings ServerSettings, schemas serverSchemas, servers []string) (*openapi3.T, error) {
+	title := settings.OpenAPI.Title
+	if title == "" {
+		title = "API"
+	}
+	version := settings.OpenAPI.Version
+	if version == "" {
+		version = "1.0.0"
+	}
+
+	doc := &openapi3.T{
+		OpenAPI: openAPIVersion,
+		Info: &openapi3.Info{
+			Title:       title,
+			Version:     version,
+			Description: settings.OpenAPI.Description,
+		},
+		Paths: openapi3.NewPaths(),
+		Components: &openapi3.Components{
+			Schemas:         openapi3.Schemas{},
+			SecuritySchemes: openapi3.SecuritySchemes{},
+		},
+	}
+	for _, s := range servers {
+		doc.AddServer(&openapi3.Server{URL: s})
+	}
+
+	validationErrors, err := portSchema(ServerValidationErrors{}, doc.Components.Schemas)
+	if err != nil {
+		return nil, err
+	}
+	// messages flows receive and answer with
+	requestMessage, err := portSchema(ServerRequest{}, doc.Components.Schemas)
+	if err != nil {
+		return nil, err
+	}
+	responseMessage, err := portSchema(ServerResponse{}, doc.Components.Schemas)
+	if err != nil {
+		return nil, err
+	}
+
+	security := openAPISecurity(settings.Auth, doc.Components.SecuritySchemes)
+	if len(security) > 0 {
+		doc.Security = security
+	}
+
+	routes := settings.Routes
+	if len(routes) == 0 {
+		// every request goes to the single request port
+		routes = []ServerRoute{{
+			Name:        ServerRequestPort,
+			Method:      RouteMethodAny,
+			Path:        "/*path",
+			Description: "Any path, handled by the Request port",
+		}}
+	}
+
+	for _, route := range routes {
+		port := ServerRequestPort
+		if len(settings.Routes) > 0 {
+			port = getRoutePortName(route.Name)
+		}
+
+		bodySchema, querySchema := settings.BodySchema, settings.QuerySchema
+		if route.BodySchema != "" {
+			bodySchema = route.BodySchema
+		}
+		if route.QuerySchema != "" {
+			querySchema = route.QuerySchema
+		}
+
+		path, params := openAPIPath(route.Path)
+
+		for _, method := range routeMethods(route.Method) {
+			op := &openapi3.Operation{
+				OperationID: operationID(route, method),
+				Summary:     route.Description,
+				Parameters:  openapi3.NewParameters(),
+				Responses: openapi3.NewResponses(openapi3.WithStatus(http.StatusOK, &openapi3.ResponseRef{
+					Value: openapi3.NewResponse().WithDescription("Response produced by the flow"),
+				})),
+				Extensions: map[string]any{
+					"x-port":             port,
+					"x-request-message":  requestMessage,
+					"x-response-message": responseMessage,
+				},
+			}
+			for _, p := range params {
+				op.Parameters = append(op.Parameters, &openapi3.ParameterRef{
+					Value: openapi3.NewPathParameter(p).WithSchema(openapi3.NewStringSchema()),
+				})
+			}
+
+			hasSchema := false
+			query, err := schemas.get(querySchema)
+			if err != nil {
+				return nil, fmt.Errorf("route %s: %v", route.Name, err)
+			}
+			if query != nil {
+				addSchemaComponents(query, doc.Components.Schemas)
+				op.Parameters = append(op.Parameters, queryParameters(query)...)
+				hasSchema = true
+			}
+			body, err := schemas.get(bodySchema)
+			if err != nil {
+				return nil, fmt.Errorf("route %s: %v", route.Name, err)
+			}
+			if body != nil && methodHasBody(method) {
+				addSchemaComponents(body, doc.Components.Schemas)
+				op.RequestBody = &openapi3.RequestBodyRef{
+					Value: openapi3.NewRequestBody().WithRequired(true).WithJSONSchema(body),
+				}
+				hasSchema = true
+			}
+
+			if hasSchema {
+				op.Responses.Set("400", &openapi3.ResponseRef{
+					Value: openapi3.NewResponse().WithDescription("Validation failed").WithJSONSchemaRef(validationErrors),
+				})
+			}
+			if len(security) > 0 {
+				op.Responses.Set("401", &openapi3.ResponseRef{
+					Value: openapi3.NewResponse().WithDescription("Unauthorized"),
+				})
+			}
+			doc.AddOperation(path, method, op)
+		}
+	}
+	return doc, nil
+}
+
+// openAPIPath converts route pattern into templated path, e.g. /users/:id to /users/{id}
+func openAPIPath(pattern string) (string, []string) {
+	var params []string
+	parts := splitPath(pattern)
+	for i, part := range parts {
+		var name string
+		switch {
+		case strings.HasPrefix(part, ":"):
+			name = part[1:]
+		case strings.HasPrefix(part, "*"):
+			name = strings.TrimPrefix(part, "*")
+			if name == "" {
+				name = "*"
+			}
+		default:
+			continue
+		}
+		params = append(params, name)
+		parts[i] = fmt.Sprintf("{%s}", name)
+	}
+	return "/" + strings.Join(parts, "/"), params
+}
+
+func routeMethods(method string) []string {
+	if method == "" || method == RouteMethodAny {
+		return []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+	}
+	return []string{strings.ToUpper(method)}
+}
+
+func methodHasBody(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		return true
+	}
+	return false
+}
+
+func operationID(route ServerRoute, method string) string {
+	if route.Method == "" || route.Method == RouteMethodAny {
+		return fmt.Sprintf("%s_%s", route.Name, strings.ToLower(method))
+	}
+	return route.Name
+}
+
+func queryParameters(s *openapi3.Schema) openapi3.Parameters {
+	required := make(map[string]bool, len(s.Required))
+	for _, r := range s.Required {
+		required[r] = true
+	}
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	params := make(openapi3.Parameters, 0, len(names))
+	for _, name := range names {
+		p := openapi3.NewQueryParameter(name).WithRequired(required[name])
+		p.Schema = s.Properties[name]
+		params = append(params, &openapi3.ParameterRef{Value: p})
+	}
+	return params
+}
+
+func openAPISecurity(auth ServerAuth, schemes openapi3.SecuritySchemes) openapi3.SecurityRequirements {
+	var security openapi3.SecurityRequirements
+
+	if auth.EnableAPIKey {
+		header := auth.APIKeyHeader
+		if header == "" {
+			header = "X-API-Key"
+		}
+		schemes["apiKey"] = &openapi3.SecuritySchemeRef{
+			Value: openapi3.NewSecurityScheme().WithType("apiKey").WithIn("header").WithName(header),
+		}
+		schemes["bearer"] = &openapi3.SecuritySchemeRef{
+			Value: openapi3.NewSecurityScheme().WithType("http").WithScheme("bearer"),
+		}
+		security = append(security, openapi3.NewSecurityRequirement().Authenticate("apiKey"), openapi3.NewSecurityRequirement().Authenticate("bearer"))
+	}
+	if auth.EnableBasic {
+		schemes["basic"] = &openapi3.SecuritySchemeRef{
+			Value: openapi3.NewSecurityScheme().WithType("http").WithScheme("basic"),
+		}
+		security = append(security, openapi3.NewSecurityRequirement().Authenticate("basic"))
+	}
+	if auth.EnableHMAC {
+		header := auth.HMACHeader
+		if header == "" {
+			header = "X-Signature"
+		}
+		schemes["hmac"] = &openapi3.SecuritySchemeRef{
+			Value: openapi3.NewSecurityScheme().WithType("apiKey").WithIn("header").WithName(header).
+				WithDescription(fmt.Sprintf("HMAC %s signature of the request body", auth.HMACAlgorithm)),
+		}
+		security = append(security, openapi3.NewSecurityRequirement().Authenticate("hmac"))
+	}
+	return security
+}
+
+// portSchema converts port message schema into components, returns reference to the message schema
+func portSchema(m any, components openapi3.Schemas) (*openapi3.SchemaRef, error) {
+	sh, err := schema.CreateSchema(m)
+	if err != nil {
+		return nil, err
+	}
+	data, err := sh.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.ReplaceAll(data, []byte("#/$defs/"), []byte("#/components/schemas/"))
+
+	var root struct {
+		Ref  string                     `json:"$ref"`
+		Defs map[string]json.RawMessage `json:"$defs"`
+	}
+	if err = json.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+	for name, def := range root.Defs {
+		s := &openapi3.Schema{}
+		if err = json.Unmarshal(def, s); err != nil {
+			return nil, fmt.Errorf("definition %s: %v", name, err)
+		}
+		clearExtensions(s)
+		components[name] = openapi3.NewSchemaRef("", s)
+	}
+	return openapi3.NewSchemaRef(root.Ref, nil), nil
+}
+
+// addSchemaComponents adds definitions the schema refers to, compiled schemas keep refs to them
+func addSchemaComponents(s *openapi3.Schema, components openapi3.Schemas) {
+	var visit func(ref *openapi3.SchemaRef)
+	visit = func(ref *openapi3.SchemaRef) {
+		if ref == nil || ref.Value == nil {
+			return
+		}
+		if name, ok := strings.CutPrefix(ref.Ref, "#/components/schemas/"); ok {
+			if _, added := components[name]; added {
+				return
+			}
+			components[name] = openapi3.NewSchemaRef("", ref.Value)
+		}
+		v := ref.Value
+		for _, p := range v.Properties {
+			visit(p)
+		}
+		visit(v.Items)
+		visit(v.AdditionalProperties.Schema)
+		visit(v.Not)
+		for _, refs := range []openapi3.SchemaRefs{v.AllOf, v.AnyOf, v.OneOf} {
+			for _, r := range refs {
+				visit(r)
+			}
+		}
+	}
+	visit(openapi3.NewSchemaRef("", s))
+}
+
+// clearExtensions removes UI specific keywords like propertyOrder or colSpan
+func clearExtensions(s *openapi3.Schema) {
+	if s == nil {
+		return
+	}
+	s.Extensions = nil
+	for _, p := range s.Properties {
+		clearExtensions(p.Value)
+	}
+	if s.Items != nil {
+		clearExtensions(s.Items.Value)
+	}
+	if s.AdditionalProperties.Schema != nil {
+		clearExtensions(s.AdditionalProperties.Schema.Value)
+	}
+	for _, refs := range []openapi3.SchemaRefs{s.AllOf, s.AnyOf, s.OneOf} {
+		for _, r := range refs {
+			clearExtensions(r.Value)
+		}
+	}
+}
+
+// openAPIDocsTemplate renders the document without external assets so it works in offline deployments
+var openAPIDocsTemplate = template.Must(template.New("docs").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body{font-family:sans-serif;margin:2em auto;max-width:60em;color:#222}
+details{border:1px solid #ddd;border-radius:4px;margin:.5em 0;padding:.5em}
+summary{cursor:pointer}
+.method{display:inline-block;min-width:5em;font-weight:bold;text-transform:uppercase}
+pre{background:#f6f6f6;padding:.5em;overflow:auto}
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p><a href="{{.Path}}">{{.Path}}</a></p>
+<div id="docs"></div>
+<script>
+(function () {
+  var root = document.getElementById("docs");
+  function el(tag, text) {
+    var e = document.createElement(tag);
+    if (text !== undefined) e.textContent = text;
+    return e;
+  }
+  function section(parent, title, value) {
+    parent.appendChild(el("h4", title));
+    parent.appendChild(el("pre", JSON.stringify(value, null, 2)));
+  }
+  fetch({{.Path}}).then(function (r) { return r.json(); }).then(function (doc) {
+    if (doc.info && doc.info.description) root.appendChild(el("p", doc.info.description));
+    Object.keys(doc.paths || {}).forEach(function (path) {
+      var item = doc.paths[path];
+      Object.keys(item).forEach(function (method) {
+        var op = item[method];
+        var d = el("details");
+        var s = el("summary");
+        s.appendChild(el("span", method)).className = "method";
+        s.appendChild(el("code", path));
+        if (op.summary) s.appendChild(el("span", " - " + op.summary));
+        d.appendChild(s);
+        if (op.parameters && op.parameters.length) section(d, "Parameters", op.parameters);
+        if (op.requestBody) section(d, "Request body", op.requestBody);
+        section(d, "Responses", op.responses);
+        root.appendChild(d);
+      });
+    });
+    if (doc.components && doc.components.schemas) section(root, "Schemas", doc.components.schemas);
+  }).catch(function (e) {
+    root.appendChild(el("p", "Unable to load document: " + e));
+  });
+})();
+</script>
+</body>
+</html>
+`))
+
+func serveOpenAPIDocs(c echo.Context, o ServerOpenAPI) error {
+	buf := &bytes.Buffer{}
+	if err := openAPIDocsTemplate.Execute(buf, o); err != nil {
+		return err
+	}
+	return c.HTMLBlob(http.StatusOK, buf.Bytes())
+}
@@ -0,0 +1,115 @@
+package http
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_openAPIPath(t *testing.T) {
+	tests := []struct {
+		pattern    string
+		wantPath   string
+		wantParams []string
+	}{
+		{pattern: "/", wantPath: "/"},
+		{pattern: "/users", wantPath: "/users"},
+		{pattern: "/users/:id/posts/:postId", wantPath: "/users/{id}/posts/{postId}", wantParams: []string{"id", "postId"}},
+		{pattern: "/files/*path", wantPath: "/files/{path}", wantParams: []string{"path"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			path, params := openAPIPath(tt.pattern)
+			if path != tt.wantPath {
+				t.Errorf("openAPIPath() path = %v, want %v", path, tt.wantPath)
+			}
+			if !reflect.DeepEqual(params, tt.wantParams) {
+				t.Errorf("openAPIPath() params = %v, want %v", params, tt.wantParams)
+			}
+		})
+	}
+}
+
+func Test_buildOpenAPI(t *testing.T) {
+	settings := ServerSettings{
+		Routes: []ServerRoute{
+			{Name: "getUser", Method: "GET", Path: "/users/:id", QuerySchema: `{"type":"object","required":["fields"],"properties":{"fields":{"type":"string"}}}`},
+			{Name: "createUser", Method: "POST", Path: "/users", BodySchema: `{"type":"object","properties":{"name":{"type":"string"}}}`},
+			{Name: "any", Method: RouteMethodAny, Path: "/any"},
+		},
+		Auth: ServerAuth{EnableBasic: true},
+	}
+
+	schemas, err := compileSchemas(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := buildOpenAPI(settings, schemas, []string{"https://example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := doc.Paths.Value("/users/{id}").Get
+	if get == nil || get.OperationID != "getUser" {
+		t.Fatalf("get operation not found")
+	}
+	if len(get.Parameters) != 2 || get.Parameters[0].Value.In != "path" || get.Parameters[1].Value.Name != "fields" || !get.Parameters[1].Value.Required {
+		t.Errorf("unexpected parameters of get operation")
+	}
+
+	post := doc.Paths.Value("/users").Post
+	if post == nil || post.RequestBody == nil || post.RequestBody.Value.Content.Get(MIMEApplicationJSON) == nil {
+		t.Fatalf("post operation should have JSON request body")
+	}
+	if post.Responses.Status(400) == nil || post.Responses.Status(401) == nil {
+		t.Errorf("post operation should declare validation and auth errors")
+	}
+
+	if ops := doc.Paths.Value("/any").Operations(); len(ops) != 5 {
+		t.Errorf("ANY route should be expanded to 5 operations, got %d", len(ops))
+	}
+
+	if _, ok := doc.Components.Schemas["Servervalidationerrors"]; !ok {
+		t.Errorf("validation errors schema should be in components")
+	}
+	if _, ok := doc.Components.SecuritySchemes["basic"]; !ok {
+		t.Errorf("basic auth security scheme expected")
+	}
+
+	for _, name := range []string{"Serverrequest", "Serverresponse"} {
+		if _, ok := doc.Components.Schemas[name]; !ok {
+			t.Errorf("port schema %s should be in components", name)
+		}
+	}
+	if port := post.Extensions["x-port"]; port != "route_createuser" {
+		t.Errorf("post operation port = %v, want route_createuser", port)
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "propertyOrder") || strings.Contains(string(data), "$defs") {
+		t.Errorf("document should not contain port schema specific keywords: %s", data)
+	}
+}
+
+func Test_buildOpenAPICatchAll(t *testing.T) {
+	settings := ServerSettings{BodySchema: `{"type":"object"}`}
+	schemas, err := compileSchemas(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := buildOpenAPI(settings, schemas, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	item := doc.Paths.Value("/{path}")
+	if item == nil {
+		t.Fatalf("catch-all path expected without routes")
+	}
+	if item.Post == nil || item.Post.RequestBody == nil || item.Post.Extensions["x-port"] != ServerRequestPort {
+		t.Errorf("catch-all operation should describe the request port and its body schema")
+	}
+}
@@ -0,0 +1,215 @@
+package http
+
+import (
+	"fmt"
+	"github.com/spyzhov/ajson"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	PaginationNone   = "none"
+	PaginationLink   = "link"
+	PaginationCursor = "cursor"
+	PaginationPage   = "page"
+	PaginationOffset = "offset"
+)
+
+const (
+	PaginationEmitPage     = "page"
+	PaginationEmitCombined = "combined"
+)
+
+// defaultMaxPages keeps pagination finite when max pages is not configured
+const defaultMaxPages = 10
+
+type ClientPagination struct {
+	Mode     string `json:"mode" title:"Mode" enum:"none,link,cursor,page,offset" enumTitles:"None,Link header,Cursor,Page number,Offset" default:"none" colSpan:"col-span-4"`
+	MaxPages int    `json:"maxPages" title:"Max pages" description:"Pagination stops after this number of pages" minimum:"1" default:"10" colSpan:"col-span-4"`
+	Emit     string `json:"emit" title:"Emit" enum:"page,combined" enumTitles:"Message per page,Combined array" default:"page" colSpan:"col-span-4"`
+
+	ItemsPath string `json:"itemsPath,omitempty" title:"Items JSONPath" description:"Items of a page, e.g. $.data. Combined array consists of items of all pages, or of whole page bodies if empty. Required by page and offset modes which stop on a page with fewer items than page size"`
+
+	CursorPath  string `json:"cursorPath,omitempty" title:"Cursor JSONPath" description:"Next page cursor in the response body, e.g. $.meta.nextCursor. Pagination stops when it is missing or empty" colSpan:"col-span-6"`
+	CursorParam string `json:"cursorParam,omitempty" title:"Cursor parameter" description:"Query parameter the cursor is sent in" colSpan:"col-span-6"`
+
+	PageParam   string `json:"pageParam,omitempty" title:"Page parameter" colSpan:"col-span-4"`
+	StartPage   int    `json:"startPage,omitempty" title:"Start page" description:"Number of the first page" colSpan:"col-span-4"`
+	OffsetParam string `json:"offsetParam,omitempty" title:"Offset parameter" colSpan:"col-span-4"`
+	LimitParam  string `json:"limitParam,omitempty" title:"Page size parameter" description:"Query parameter page size is sent in, not sent if empty" colSpan:"col-span-6"`
+	PageSize    int    `json:"pageSize,omitempty" title:"Page size" minimum:"0" colSpan:"col-span-6"`
+}
+
+func (p ClientPagination) enabled() bool {
+	return p.Mode != "" && p.Mode != PaginationNone
+}
+
+func (p ClientPagination) validate() error {
+	switch p.Mode {
+	case "", PaginationNone, PaginationLink:
+	case PaginationCursor:
+		if p.CursorPath == "" || p.CursorParam == "" {
+			return fmt.Errorf("cursor pagination requires cursor JSONPath and parameter")
+		}
+	case PaginationPage, PaginationOffset:
+		if p.ItemsPath == "" {
+			return fmt.Errorf("%s pagination requires items JSONPath", p.Mode)
+		}
+		if p.Mode == PaginationPage && p.PageParam == "" || p.Mode == PaginationOffset && p.OffsetParam == "" {
+			return fmt.Errorf("%s pagination requires %s parameter", p.Mode, p.Mode)
+		}
+	default:
+		return fmt.Errorf("unknown pagination mode %s", p.Mode)
+	}
+	return nil
+}
+
+func (p ClientPagination) maxPages() int {
+	if !p.enabled() {
+		return 1
+	}
+	if p.MaxPages < 1 {
+		return defaultMaxPages
+	}
+	return p.MaxPages
+}
+
+// pageURL sets page or offset query parameters of the given page starting with 1
+func (p ClientPagination) pageURL(rawURL string, page int) (string, error) {
+	if p.Mode != PaginationPage && p.Mode != PaginationOffset {
+		return rawURL, nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	if p.Mode == PaginationPage {
+		q.Set(p.PageParam, strconv.Itoa(p.StartPage+page-1))
+	} else {
+		q.Set(p.OffsetParam, strconv.Itoa((page-1)*p.PageSize))
+	}
+	if p.LimitParam != "" && p.PageSize > 0 {
+		q.Set(p.LimitParam, strconv.Itoa(p.PageSize))
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// items of the page, body is the decoded response body
+func (p ClientPagination) items(raw []byte, body any) ([]any, error) {
+	if !p.enabled() {
+		return nil, nil
+	}
+	if p.ItemsPath == "" {
+		return []any{body}, nil
+	}
+	nodes, err := ajson.JSONPath(raw, p.ItemsPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to find items: %v", err)
+	}
+	// path pointing to an array means its elements
+	if len(nodes) == 1 && nodes[0].IsArray() {
+		nodes, _ = nodes[0].GetArray()
+	}
+	items := make([]any, 0, len(nodes))
+	for _, node := range nodes {
+		item, err := node.Unpack()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// next returns URL of the page after given one, empty if there are no more pages
+func (p ClientPagination) next(current string, resp *clientResponse, page int, items int) (string, error) {
+	switch p.Mode {
+	case PaginationLink:
+		link := nextLink(resp.header)
+		if link == "" {
+			return "", nil
+		}
+		base, err := url.Parse(current)
+		if err != nil {
+			return "", err
+		}
+		u, err := base.Parse(link)
+		if err != nil {
+			return "", fmt.Errorf("invalid next link: %v", err)
+		}
+		return u.String(), nil
+
+	case PaginationCursor:
+		nodes, err := ajson.JSONPath(resp.body, p.CursorPath)
+		if err != nil {
+			return "", fmt.Errorf("unable to find cursor: %v", err)
+		}
+		if len(nodes) == 0 || nodes[0].IsNull() {
+			return "", nil
+		}
+		value, err := nodes[0].Unpack()
+		if err != nil {
+			return "", err
+		}
+		cursor := fmt.Sprint(value)
+		if cursor == "" {
+			return "", nil
+		}
+		u, err := url.Parse(current)
+		if err != nil {
+			return "", err
+		}
+		q := u.Query()
+		q.Set(p.CursorParam, cursor)
+		u.RawQuery = q.Encode()
+		return u.String(), nil
+
+	case PaginationPage, PaginationOffset:
+		if items == 0 || items < p.PageSize {
+			return "", nil
+		}
+		if p.Mode == PaginationPage {
+			return p.pageURL(current, page+1)
+		}
+		// offset moves by the number of items received, page size could be capped by the server
+		u, err := url.Parse(current)
+		if err != nil {
+			return "", err
+		}
+		q := u.Query()
+		offset, _ := strconv.Atoi(q.Get(p.OffsetParam))
+		q.Set(p.OffsetParam, strconv.Itoa(offset+items))
+		u.RawQuery = q.Encode()
+		return u.String(), nil
+	}
+	return "", nil
+}
+
+// nextLink finds rel="next" URL in Link headers
+func nextLink(header http.Header) string {
+	for _, value := range header.Values("Link") {
+		for _, link := range strings.Split(value, ",") {
+			parts := strings.Split(link, ";")
+			target := strings.TrimSpace(parts[0])
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			for _, param := range parts[1:] {
+				name, rel, ok := strings.Cut(strings.TrimSpace(param), "=")
+				if !ok || !strings.EqualFold(name, "rel") {
+					continue
+				}
+				for _, r := range strings.Fields(strings.Trim(rel, `"`)) {
+					if strings.EqualFold(r, "next") {
+						return target[1 : len(target)-1]
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
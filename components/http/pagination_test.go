@@ -0,0 +1,165 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func Test_nextLink(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "", want: ""},
+		{value: `<https://api.example.com/items?page=2>; rel="next"`, want: "https://api.example.com/items?page=2"},
+		{value: `<https://api.example.com/items?page=1>; rel="prev", </items?page=3>; rel="next"`, want: "/items?page=3"},
+		{value: `</items?page=3>; rel=next`, want: "/items?page=3"},
+		{value: `</items?page=9>; rel="last"`, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			header := http.Header{}
+			if tt.value != "" {
+				header.Set("Link", tt.value)
+			}
+			if got := nextLink(header); got != tt.want {
+				t.Errorf("nextLink() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientPagination_pageURL(t *testing.T) {
+	tests := []struct {
+		name       string
+		pagination ClientPagination
+		page       int
+		want       string
+	}{
+		{name: "not paged", pagination: ClientPagination{Mode: PaginationLink}, page: 2, want: "http://a/items?q=1"},
+		{name: "page", pagination: ClientPagination{Mode: PaginationPage, PageParam: "page", StartPage: 1}, page: 2, want: "http://a/items?page=2&q=1"},
+		{name: "zero based page", pagination: ClientPagination{Mode: PaginationPage, PageParam: "p"}, page: 1, want: "http://a/items?p=0&q=1"},
+		{name: "offset", pagination: ClientPagination{Mode: PaginationOffset, OffsetParam: "offset", LimitParam: "limit", PageSize: 20}, page: 3, want: "http://a/items?limit=20&offset=40&q=1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.pagination.pageURL("http://a/items?q=1", tt.page)
+			if err != nil || got != tt.want {
+				t.Errorf("pageURL() = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_pagination(t *testing.T) {
+	// 5 items served by 2 per page
+	items := []int{1, 2, 3, 4, 5}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+			offset, _ = strconv.Atoi(cursor)
+		}
+		if page := r.URL.Query().Get("page"); page != "" {
+			p, _ := strconv.Atoi(page)
+			offset = (p - 1) * 2
+		}
+		end := min(offset+2, len(items))
+
+		next := "null"
+		if end < len(items) {
+			next = strconv.Itoa(end)
+			w.Header().Set("Link", fmt.Sprintf(`</items?offset=%d>; rel="next"`, end))
+		}
+		w.Header().Set(HeaderContentType, MIMEApplicationJSON)
+		data := "["
+		for i, item := range items[offset:end] {
+			if i > 0 {
+				data += ","
+			}
+			data += strconv.Itoa(item)
+		}
+		_, _ = fmt.Fprintf(w, `{"data":%s,"next":%s}`, data+"]", next)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		pagination ClientPagination
+		wantPages  []int
+		wantBody   any
+	}{
+		{
+			name:       "disabled",
+			pagination: ClientPagination{Mode: PaginationNone},
+			wantPages:  []int{1},
+		},
+		{
+			name:       "link combined",
+			pagination: ClientPagination{Mode: PaginationLink, ItemsPath: "$.data", Emit: PaginationEmitCombined},
+			wantPages:  []int{3},
+			wantBody:   []any{float64(1), float64(2), float64(3), float64(4), float64(5)},
+		},
+		{
+			name:       "link max pages",
+			pagination: ClientPagination{Mode: PaginationLink, MaxPages: 2},
+			wantPages:  []int{1, 2},
+		},
+		{
+			name:       "cursor combined",
+			pagination: ClientPagination{Mode: PaginationCursor, CursorPath: "$.next", CursorParam: "cursor", ItemsPath: "$.data[*]", Emit: PaginationEmitCombined},
+			wantPages:  []int{3},
+			wantBody:   []any{float64(1), float64(2), float64(3), float64(4), float64(5)},
+		},
+		{
+			name:       "page per message",
+			pagination: ClientPagination{Mode: PaginationPage, PageParam: "page", StartPage: 1, PageSize: 2, ItemsPath: "$.data"},
+			wantPages:  []int{1, 2, 3},
+		},
+		{
+			name:       "offset stops on empty page",
+			pagination: ClientPagination{Mode: PaginationOffset, OffsetParam: "offset", ItemsPath: "$.data", Emit: PaginationEmitCombined},
+			wantPages:  []int{4},
+			wantBody:   []any{float64(1), float64(2), float64(3), float64(4), float64(5)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := (&Client{}).Instance().(*Client)
+
+			var got []ClientResponse
+			handler := func(ctx context.Context, port string, data any) error {
+				if resp, ok := data.(ClientResponse); ok {
+					got = append(got, resp)
+				}
+				return nil
+			}
+
+			err := client.Handle(context.Background(), handler, ClientRequestPort, ClientRequest{
+				Request: ClientRequestRequest{
+					Method:     http.MethodGet,
+					URL:        server.URL + "/items",
+					Pagination: tt.pagination,
+				},
+			})
+			if err != nil {
+				t.Fatalf("Handle() error = %v", err)
+			}
+
+			pages := make([]int, 0, len(got))
+			for _, resp := range got {
+				pages = append(pages, resp.Page)
+			}
+			if !reflect.DeepEqual(pages, tt.wantPages) {
+				t.Fatalf("pages = %v, want %v", pages, tt.wantPages)
+			}
+			if tt.wantBody != nil && !reflect.DeepEqual(got[0].Response.Body, tt.wantBody) {
+				t.Errorf("body = %v, want %v", got[0].Response.Body, tt.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package http
+
+import (
+	"context"
+	"github.com/tiny-systems/main/pkg/ttlmap"
+	"golang.org/x/time/rate"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	RateLimitKeyIP     = "ip"
+	RateLimitKeyHeader = "header"
+)
+
+// limiters of clients which did not send requests for that long are forgotten
+const rateLimiterTTL = 600
+
+type ServerRateLimit struct {
+	Enable            bool    `json:"enable" title:"Enable rate limiting"`
+	RequestsPerSecond float64 `json:"requestsPerSecond" title:"Requests per second" description:"Sustained request rate allowed for a single client. Zero disables per client limit" minimum:"0"`
+	Burst             int     `json:"burst" title:"Burst" description:"Number of requests a client can make at once" minimum:"1"`
+	KeyBy             string  `json:"keyBy" title:"Client key" enum:"ip,header" enumTitles:"Real IP,Header value" default:"ip"`
+	KeyHeader         string  `json:"keyHeader" title:"Client key header" description:"Header identifying a client when keyed by header value. Clients without the header are keyed by real IP"`
+	MaxInFlight       int     `json:"maxInFlight" title:"Max in-flight requests" description:"Requests above this number are rejected with 503. Zero means unlimited" minimum:"0"`
+}
+
+// rateLimiter keeps token bucket per client
+type rateLimiter struct {
+	limiters *ttlmap.TTLMap
+	lock     *sync.Mutex
+}
+
+func newRateLimiter(ctx context.Context) *rateLimiter {
+	return &rateLimiter{
+		limiters: ttlmap.New(ctx, rateLimiterTTL),
+		lock:     &sync.Mutex{},
+	}
+}
+
+// allow takes token from the client's bucket, returns how long client should wait if bucket is empty
+func (r *rateLimiter) allow(settings ServerRateLimit, key string) (bool, time.Duration) {
+	if settings.RequestsPerSecond <= 0 {
+		return true, 0
+	}
+
+	burst := settings.Burst
+	if burst < 1 {
+		burst = 1
+	}
+
+	r.lock.Lock()
+	limiter, ok := r.limiters.Get(key).(*rate.Limiter)
+	if !ok {
+		limiter = rate.NewLimiter(rate.Limit(settings.RequestsPerSecond), burst)
+		r.limiters.Put(key, limiter)
+	}
+	r.lock.Unlock()
+
+	// settings may change while server is running
+	limiter.SetLimit(rate.Limit(settings.RequestsPerSecond))
+	limiter.SetBurst(burst)
+
+	reservation := limiter.Reserve()
+	if !reservation.OK() {
+		return false, time.Second
+	}
+	delay := reservation.Delay()
+	if delay == 0 {
+		return true, 0
+	}
+	reservation.Cancel()
+	return false, delay
+}
+
+// rateLimitKey identifies client, clients without the key header are limited by their IP
+func rateLimitKey(settings ServerRateLimit, req *http.Request, realIP string) string {
+	if settings.KeyBy == RateLimitKeyHeader && settings.KeyHeader != "" {
+		if value := req.Header.Get(settings.KeyHeader); value != "" {
+			// header values can not take bucket of an IP
+			return RateLimitKeyHeader + ":" + value
+		}
+	}
+	return RateLimitKeyIP + ":" + realIP
+}
+
+// retryAfter formats Retry-After header value, whole seconds rounded up
+func retryAfter(d time.Duration) string {
+	seconds := int64(math.Ceil(d.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	return strconv.FormatInt(seconds, 10)
+}
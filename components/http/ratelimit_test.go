@@ -0,0 +1,98 @@
+package http
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_rateLimiter_allow(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	limiter := newRateLimiter(ctx)
+	settings := ServerRateLimit{RequestsPerSecond: 10, Burst: 2}
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := limiter.allow(settings, "a"); !ok {
+			t.Fatalf("request %d within burst should be allowed", i)
+		}
+	}
+
+	ok, wait := limiter.allow(settings, "a")
+	if ok {
+		t.Fatalf("request above burst should be rejected")
+	}
+	if wait <= 0 || wait > 100*time.Millisecond {
+		t.Errorf("allow() wait = %v, want up to 100ms", wait)
+	}
+
+	if ok, _ = limiter.allow(settings, "b"); !ok {
+		t.Errorf("other client should have its own bucket")
+	}
+
+	// bucket refills at configured rate, rejected requests do not consume tokens
+	time.Sleep(wait + 10*time.Millisecond)
+	if ok, _ = limiter.allow(settings, "a"); !ok {
+		t.Errorf("request should be allowed after refill")
+	}
+	if ok, _ = limiter.allow(settings, "a"); ok {
+		t.Errorf("refill should add a single token")
+	}
+}
+
+func Test_rateLimiter_allowUnlimited(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	limiter := newRateLimiter(ctx)
+	for i := 0; i < 100; i++ {
+		if ok, _ := limiter.allow(ServerRateLimit{}, "a"); !ok {
+			t.Fatalf("zero rate should not limit requests")
+		}
+	}
+}
+
+func Test_rateLimitKey(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Client", "client-1")
+
+	tests := []struct {
+		name     string
+		settings ServerRateLimit
+		want     string
+	}{
+		{name: "ip", settings: ServerRateLimit{KeyBy: RateLimitKeyIP}, want: "ip:10.0.0.1"},
+		{name: "header", settings: ServerRateLimit{KeyBy: RateLimitKeyHeader, KeyHeader: "X-Client"}, want: "header:client-1"},
+		{name: "header without name falls back to ip", settings: ServerRateLimit{KeyBy: RateLimitKeyHeader}, want: "ip:10.0.0.1"},
+		{name: "missing header falls back to ip", settings: ServerRateLimit{KeyBy: RateLimitKeyHeader, KeyHeader: "X-Tenant"}, want: "ip:10.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rateLimitKey(tt.settings, req, "10.0.0.1"); got != tt.want {
+				t.Errorf("rateLimitKey() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_retryAfter(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{d: 0, want: "1"},
+		{d: 10 * time.Millisecond, want: "1"},
+		{d: time.Second, want: "1"},
+		{d: 1001 * time.Millisecond, want: "2"},
+		{d: 90 * time.Second, want: "90"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.d.String(), func(t *testing.T) {
+			if got := retryAfter(tt.d); got != tt.want {
+				t.Errorf("retryAfter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
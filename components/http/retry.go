@@ -0,0 +1,146 @@
+package http
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type ClientRetry struct {
+	MaxAttempts       int   `json:"maxAttempts" title:"Max attempts" description:"Total number of attempts including the first one. Zero or one disables retries" minimum:"0" colSpan:"col-span-4"`
+	BackoffBase       int   `json:"backoffBase" title:"Backoff base" description:"Delay before the first retry, milliseconds. Doubles with every next retry" minimum:"0" colSpan:"col-span-4"`
+	BackoffMax        int   `json:"backoffMax" title:"Backoff max" description:"Maximum delay between attempts, milliseconds. Zero means unlimited" minimum:"0" colSpan:"col-span-4"`
+	Jitter            bool  `json:"jitter" title:"Jitter" description:"Randomize delays so clients do not retry in sync"`
+	RetryStatusCodes  []int `json:"retryStatusCodes" title:"Retryable status codes" description:"Response status codes which are retried. Network errors and timeouts are always retried"`
+	RespectRetryAfter bool  `json:"respectRetryAfter" title:"Respect Retry-After" description:"Wait as long as Retry-After response header says, up to backoff max"`
+}
+
+type ClientCircuitBreaker struct {
+	Enable           bool `json:"enable" title:"Enable circuit breaker" description:"Requests to a host failing in a row are rejected without sending them until open timeout passes"`
+	FailureThreshold int  `json:"failureThreshold" title:"Failure threshold" description:"Consecutive network errors or 5xx responses which open the circuit" minimum:"1" default:"5"`
+	OpenTimeout      int  `json:"openTimeout" title:"Open timeout" description:"Seconds the circuit stays open before a single trial request is let through" minimum:"1" default:"30"`
+}
+
+func (r ClientRetry) retryStatus(statusCode int) bool {
+	for _, code := range r.RetryStatusCodes {
+		if code == statusCode {
+			return true
+		}
+	}
+	return false
+}
+
+// delay before the next attempt, attempt is the number of the failed one starting with 1
+func (r ClientRetry) delay(attempt int, header http.Header) time.Duration {
+	maxDelay := time.Duration(r.BackoffMax) * time.Millisecond
+
+	if r.RespectRetryAfter && header != nil {
+		if d, ok := parseRetryAfter(header.Get("Retry-After"), time.Now()); ok {
+			if maxDelay > 0 && d > maxDelay {
+				return maxDelay
+			}
+			return d
+		}
+	}
+
+	d := time.Duration(r.BackoffBase) * time.Millisecond
+	for i := 1; i < attempt && (maxDelay <= 0 || d < maxDelay); i++ {
+		d *= 2
+	}
+	if maxDelay > 0 && d > maxDelay {
+		d = maxDelay
+	}
+	if r.Jitter && d > 0 {
+		// keep at least half of the delay
+		d = d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+	}
+	return d
+}
+
+// parseRetryAfter reads Retry-After header given in seconds or as HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	t, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if d := t.Sub(now); d > 0 {
+		return d, true
+	}
+	return 0, true
+}
+
+// circuitBreaker tracks consecutive failures per host
+type circuitBreaker struct {
+	lock  *sync.Mutex
+	hosts map[string]*circuitState
+}
+
+type circuitState struct {
+	failures int
+	open     bool
+	openedAt time.Time
+	// single trial request is in flight while circuit is half open
+	probing bool
+}
+
+func newCircuitBreaker() *circuitBreaker {
+	return &circuitBreaker{
+		lock:  &sync.Mutex{},
+		hosts: make(map[string]*circuitState),
+	}
+}
+
+// allow tells if request to the host can be sent
+func (b *circuitBreaker) allow(settings ClientCircuitBreaker, host string, now time.Time) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	state, ok := b.hosts[host]
+	if !ok || !state.open {
+		return true
+	}
+	if now.Sub(state.openedAt) < time.Duration(settings.OpenTimeout)*time.Second || state.probing {
+		return false
+	}
+	state.probing = true
+	return true
+}
+
+// record result of the request to the host
+func (b *circuitBreaker) record(settings ClientCircuitBreaker, host string, failed bool, now time.Time) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if !failed {
+		delete(b.hosts, host)
+		return
+	}
+
+	state, ok := b.hosts[host]
+	if !ok {
+		state = &circuitState{}
+		b.hosts[host] = state
+	}
+	state.failures++
+
+	threshold := settings.FailureThreshold
+	if threshold < 1 {
+		threshold = 1
+	}
+	if state.probing || state.failures >= threshold {
+		state.open = true
+		state.openedAt = now
+		state.probing = false
+	}
+}
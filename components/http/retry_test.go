@@ -0,0 +1,152 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientRetry_delay(t *testing.T) {
+	retryAfter := http.Header{}
+	retryAfter.Set("Retry-After", "3")
+
+	tests := []struct {
+		name    string
+		retry   ClientRetry
+		attempt int
+		header  http.Header
+		want    time.Duration
+	}{
+		{name: "first retry", retry: ClientRetry{BackoffBase: 100}, attempt: 1, want: 100 * time.Millisecond},
+		{name: "exponential", retry: ClientRetry{BackoffBase: 100}, attempt: 4, want: 800 * time.Millisecond},
+		{name: "capped", retry: ClientRetry{BackoffBase: 100, BackoffMax: 500}, attempt: 10, want: 500 * time.Millisecond},
+		{name: "retry after", retry: ClientRetry{BackoffBase: 100, RespectRetryAfter: true}, attempt: 1, header: retryAfter, want: 3 * time.Second},
+		{name: "retry after capped", retry: ClientRetry{BackoffBase: 100, BackoffMax: 1000, RespectRetryAfter: true}, attempt: 1, header: retryAfter, want: time.Second},
+		{name: "retry after ignored", retry: ClientRetry{BackoffBase: 100}, attempt: 1, header: retryAfter, want: 100 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.retry.delay(tt.attempt, tt.header); got != tt.want {
+				t.Errorf("delay() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientRetry_delayJitter(t *testing.T) {
+	retry := ClientRetry{BackoffBase: 1000, Jitter: true}
+	for i := 0; i < 100; i++ {
+		if got := retry.delay(1, nil); got < 500*time.Millisecond || got > time.Second {
+			t.Fatalf("delay() = %v, want between 500ms and 1s", got)
+		}
+	}
+}
+
+func Test_parseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value  string
+		want   time.Duration
+		wantOk bool
+	}{
+		{value: "", wantOk: false},
+		{value: "120", want: 2 * time.Minute, wantOk: true},
+		{value: "-1", wantOk: false},
+		{value: "Mon, 01 Jan 2024 00:00:30 GMT", want: 30 * time.Second, wantOk: true},
+		{value: "Sun, 31 Dec 2023 23:00:00 GMT", want: 0, wantOk: true},
+		{value: "soon", wantOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, ok := parseRetryAfter(tt.value, now)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("parseRetryAfter() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func Test_circuitBreaker(t *testing.T) {
+	settings := ClientCircuitBreaker{Enable: true, FailureThreshold: 2, OpenTimeout: 10}
+	b := newCircuitBreaker()
+	now := time.Now()
+
+	b.record(settings, "a", true, now)
+	if !b.allow(settings, "a", now) {
+		t.Fatalf("circuit should be closed below threshold")
+	}
+	b.record(settings, "a", true, now)
+	if b.allow(settings, "a", now) {
+		t.Fatalf("circuit should open at threshold")
+	}
+	if !b.allow(settings, "b", now) {
+		t.Fatalf("other hosts should not be affected")
+	}
+
+	later := now.Add(11 * time.Second)
+	if !b.allow(settings, "a", later) {
+		t.Fatalf("trial request should be allowed after open timeout")
+	}
+	if b.allow(settings, "a", later) {
+		t.Fatalf("only a single trial request should be allowed")
+	}
+
+	// failed trial opens circuit again
+	b.record(settings, "a", true, later)
+	if b.allow(settings, "a", later.Add(time.Second)) {
+		t.Fatalf("circuit should open again after failed trial")
+	}
+
+	later = later.Add(11 * time.Second)
+	if !b.allow(settings, "a", later) {
+		t.Fatalf("trial request should be allowed after open timeout")
+	}
+	b.record(settings, "a", false, later)
+	if !b.allow(settings, "a", later) || !b.allow(settings, "a", later) {
+		t.Fatalf("successful trial should close circuit")
+	}
+}
+
+func TestClient_retry(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set(HeaderContentType, MimeTextPlain)
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	client := (&Client{}).Instance().(*Client)
+
+	var got ClientResponse
+	handler := func(ctx context.Context, port string, data any) error {
+		got, _ = data.(ClientResponse)
+		return nil
+	}
+
+	err := client.Handle(context.Background(), handler, ClientRequestPort, ClientRequest{
+		Request: ClientRequestRequest{
+			Method:  http.MethodGet,
+			URL:     server.URL,
+			Timeout: 5,
+			Retry: ClientRetry{
+				MaxAttempts:      3,
+				BackoffBase:      1,
+				RetryStatusCodes: []int{http.StatusServiceUnavailable},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Handle() error = %v", err)
+	}
+	if got.Attempts != 3 || got.Response.StatusCode != http.StatusOK || got.Response.Body != "ok" {
+		t.Errorf("Handle() response = %+v, want 200 after 3 attempts", got)
+	}
+}
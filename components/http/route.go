@@ -0,0 +1,114 @@
+package http
+
+import (
+	"fmt"
+	"strings"
+)
+
+const RouteMethodAny = "ANY"
+
+type ServerRoute struct {
+	Name   string `json:"name" required:"true" title:"Name" minLength:"1" description:"Unique route name of letters, digits, dashes and underscores. Each route gets its own output port." colSpan:"col-span-4"`
+	Method string `json:"method" required:"true" title:"Method" enum:"ANY,GET,POST,PATCH,PUT,DELETE,HEAD,OPTIONS" enumTitles:"ANY,GET,POST,PATCH,PUT,DELETE,HEAD,OPTIONS" default:"ANY" colSpan:"col-span-2"`
+	Path   string `json:"path" required:"true" title:"Path" minLength:"1" description:"Path pattern, e.g. /users/:id or /static/*" colSpan:"col-span-6"`
+
+	Description string `json:"description,omitempty" title:"Description" description:"Shown as operation summary in the OpenAPI document"`
+
+	BodySchema  string `json:"bodySchema,omitempty" title:"Body schema" format:"textarea" description:"JSON Schema the request body is validated against"`
+	QuerySchema string `json:"querySchema,omitempty" title:"Query schema" format:"textarea" description:"JSON Schema object for query params"`
+}
+
+// matchRoute checks method and path of the request against the route, returns path params if matched
+func matchRoute(route ServerRoute, method string, path string) (map[string]string, bool) {
+	if route.Method != "" && route.Method != RouteMethodAny && !strings.EqualFold(route.Method, method) {
+		return nil, false
+	}
+
+	patternParts := splitPath(route.Path)
+	pathParts := splitPath(path)
+
+	params := make(map[string]string)
+
+	for i, part := range patternParts {
+		if part == "*" || strings.HasPrefix(part, "*") {
+			// wildcard captures the rest of the path
+			name := strings.TrimPrefix(part, "*")
+			if name == "" {
+				name = "*"
+			}
+			if i < len(pathParts) {
+				params[name] = strings.Join(pathParts[i:], "/")
+			} else {
+				params[name] = ""
+			}
+			return params, true
+		}
+		if i >= len(pathParts) {
+			return nil, false
+		}
+		if strings.HasPrefix(part, ":") {
+			params[part[1:]] = pathParts[i]
+			continue
+		}
+		if part != pathParts[i] {
+			return nil, false
+		}
+	}
+
+	if len(patternParts) != len(pathParts) {
+		return nil, false
+	}
+	return params, true
+}
+
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return []string{}
+	}
+	return strings.Split(path, "/")
+}
+
+func getRoutePortName(route string) string {
+	return fmt.Sprintf("route_%s", strings.ToLower(route))
+}
+
+// resolveRoute finds the first route matching the request
+func resolveRoute(routes []ServerRoute, method string, path string) (ServerRoute, map[string]string, bool) {
+	for _, route := range routes {
+		if params, ok := matchRoute(route, method, path); ok {
+			return route, params, true
+		}
+	}
+	return ServerRoute{}, nil, false
+}
+
+func validateRoutes(routes []ServerRoute) error {
+	names := make(map[string]struct{}, len(routes))
+	for _, route := range routes {
+		if route.Name == "" {
+			return fmt.Errorf("route name can not be empty")
+		}
+		if !isPortSafeName(route.Name) {
+			return fmt.Errorf("route name %s: only letters, digits, dashes and underscores are allowed", route.Name)
+		}
+		if _, ok := names[strings.ToLower(route.Name)]; ok {
+			return fmt.Errorf("duplicate route name: %s", route.Name)
+		}
+		names[strings.ToLower(route.Name)] = struct{}{}
+		if !strings.HasPrefix(route.Path, "/") {
+			return fmt.Errorf("route %s: path should start with /", route.Name)
+		}
+	}
+	return nil
+}
+
+// isPortSafeName checks name becomes a valid port name once lowercased
+func isPortSafeName(name string) bool {
+	for _, r := range strings.ToLower(name) {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '_' && r != '-' {
+			return false
+		}
+	}
+	return true
+}
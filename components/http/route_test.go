@@ -0,0 +1,118 @@
+package http
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_matchRoute(t *testing.T) {
+	tests := []struct {
+		name       string
+		route      ServerRoute
+		method     string
+		path       string
+		wantParams map[string]string
+		wantOk     bool
+	}{
+		{
+			name:       "static path",
+			route:      ServerRoute{Method: "GET", Path: "/users"},
+			method:     "GET",
+			path:       "/users",
+			wantParams: map[string]string{},
+			wantOk:     true,
+		},
+		{
+			name:   "method mismatch",
+			route:  ServerRoute{Method: "POST", Path: "/users"},
+			method: "GET",
+			path:   "/users",
+		},
+		{
+			name:       "any method and path param",
+			route:      ServerRoute{Method: RouteMethodAny, Path: "/users/:id"},
+			method:     "DELETE",
+			path:       "/users/42/",
+			wantParams: map[string]string{"id": "42"},
+			wantOk:     true,
+		},
+		{
+			name:   "path too long",
+			route:  ServerRoute{Method: "GET", Path: "/users/:id"},
+			method: "GET",
+			path:   "/users/42/posts",
+		},
+		{
+			name:       "wildcard",
+			route:      ServerRoute{Method: "GET", Path: "/static/*"},
+			method:     "GET",
+			path:       "/static/css/main.css",
+			wantParams: map[string]string{"*": "css/main.css"},
+			wantOk:     true,
+		},
+		{
+			name:       "named wildcard",
+			route:      ServerRoute{Method: "GET", Path: "/files/*path"},
+			method:     "GET",
+			path:       "/files",
+			wantParams: map[string]string{"path": ""},
+			wantOk:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, ok := matchRoute(tt.route, tt.method, tt.path)
+			if ok != tt.wantOk {
+				t.Fatalf("matchRoute() ok = %v, want %v", ok, tt.wantOk)
+			}
+			if ok && !reflect.DeepEqual(params, tt.wantParams) {
+				t.Errorf("matchRoute() params = %v, want %v", params, tt.wantParams)
+			}
+		})
+	}
+}
+
+func Test_validateRoutes(t *testing.T) {
+	tests := []struct {
+		name    string
+		routes  []ServerRoute
+		wantErr bool
+	}{
+		{
+			name:   "valid names",
+			routes: []ServerRoute{{Name: "get_users", Path: "/users"}, {Name: "Create-User2", Path: "/users"}},
+		},
+		{
+			name:    "empty name",
+			routes:  []ServerRoute{{Name: "", Path: "/users"}},
+			wantErr: true,
+		},
+		{
+			name:    "space in name",
+			routes:  []ServerRoute{{Name: "get users", Path: "/users"}},
+			wantErr: true,
+		},
+		{
+			name:    "punctuation in name",
+			routes:  []ServerRoute{{Name: "users.list", Path: "/users"}},
+			wantErr: true,
+		},
+		{
+			name:    "duplicate name ignoring case",
+			routes:  []ServerRoute{{Name: "users", Path: "/users"}, {Name: "Users", Path: "/people"}},
+			wantErr: true,
+		},
+		{
+			name:    "relative path",
+			routes:  []ServerRoute{{Name: "users", Path: "users"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateRoutes(tt.routes); (err != nil) != tt.wantErr {
+				t.Errorf("validateRoutes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
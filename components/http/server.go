@@ -1,12 +1,16 @@
 package http
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"github.com/clbanning/mxj/v2"
 	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
 	"github.com/labstack/echo/v4"
+	cmap "github.com/orcaman/concurrent-map/v2"
 	"github.com/swaggest/jsonschema-go"
 	"github.com/tiny-systems/main/pkg/ttlmap"
 	"github.com/tiny-systems/main/pkg/utils"
@@ -15,6 +19,8 @@ import (
 	"github.com/tiny-systems/module/registry"
 	"go.uber.org/atomic"
 	"io"
+	"io/fs"
+	"mime"
 	"net"
 	"net/http"
 	"net/url"
@@ -34,25 +40,34 @@ const (
 	MIMETextHTML        = "text/html"
 	MIMEApplicationForm = "application/x-www-form-urlencoded"
 	MIMEMultipartForm   = "multipart/form-data"
+	MIMEOctetStream     = "application/octet-stream"
 )
 
 const (
-	ServerComponent    string = "http_server"
-	ServerResponsePort        = "response"
-	ServerRequestPort         = "request"
-	ServerStartPort           = "start"
-	ServerStopPort            = "stop"
-	ServerStatusPort          = "status"
+	ServerComponent     string = "http_server"
+	ServerResponsePort         = "response"
+	ServerRequestPort          = "request"
+	ServerStartPort            = "start"
+	ServerStopPort             = "stop"
+	ServerStatusPort           = "status"
+	ServerNotFoundPort         = "not_found"
+	ServerAccessLogPort        = "access_log"
 )
 
 type Server struct {
 	//e            *echo.Echo
 	settings     ServerSettings
 	settingsLock *sync.Mutex
+	// used as modification time of static files defined in settings
+	settingsTime time.Time
+	// body and query schemas of settings
+	schemas serverSchemas
 	//
 	startSettings ServerStart
 	//
 	contexts *ttlmap.TTLMap
+	//
+	wsConnections cmap.ConcurrentMap[string, *wsConnection]
 
 	publicListenAddrLock *sync.Mutex
 	publicListenAddr     []string
@@ -66,6 +81,19 @@ type Server struct {
 	startContext ServerStartContext
 	startErr     *atomic.Error
 	//
+	inFlight         *atomic.Int64
+	rateLimitedCount *atomic.Int64
+	overloadedCount  *atomic.Int64
+	//
+	draining     *atomic.Bool
+	drainedCount *atomic.Int64
+	abortedCount *atomic.Int64
+	//
+	metrics *metricsCollector
+	//
+	cacheHits   *atomic.Int64
+	cacheMisses *atomic.Int64
+	//
 	node v1alpha1.TinyNode
 
 	// k8s client wrapper
@@ -83,15 +111,63 @@ func (h *Server) Instance() module.Component {
 		//
 		settingsLock: &sync.Mutex{},
 		//
+		wsConnections: cmap.New[*wsConnection](),
+		//
 		startErr: &atomic.Error{},
+		//
+		inFlight:         &atomic.Int64{},
+		rateLimitedCount: &atomic.Int64{},
+		overloadedCount:  &atomic.Int64{},
+		//
+		draining:     &atomic.Bool{},
+		drainedCount: &atomic.Int64{},
+		abortedCount: &atomic.Int64{},
+		//
+		metrics: newMetricsCollector(),
+		//
+		cacheHits:   &atomic.Int64{},
+		cacheMisses: &atomic.Int64{},
 		startSettings: ServerStart{
-			WriteTimeout: 10,
-			ReadTimeout:  60,
-			AutoHostName: true,
+			WriteTimeout:    10,
+			ReadTimeout:     60,
+			AutoHostName:    true,
+			DrainTimeout:    10,
+			DrainStatusCode: http.StatusServiceUnavailable,
 		},
 		settings: ServerSettings{
-			EnableStatusPort: false,
-			EnableStopPort:   false,
+			EnableStatusPort:   false,
+			EnableStopPort:     false,
+			Routes:             []ServerRoute{},
+			NotFoundStatusCode: http.StatusNotFound,
+			MaxUploadSize:      10 << 20,
+			MetricsInterval:    10,
+			OpenAPI: ServerOpenAPI{
+				Path:    "/openapi.json",
+				Title:   "API",
+				Version: "1.0.0",
+			},
+			Cache: ServerCache{
+				TTL:                 60,
+				MaxEntries:          1000,
+				RespectCacheControl: true,
+			},
+			Auth: ServerAuth{
+				APIKeyHeader:  "X-API-Key",
+				BasicRealm:    "Restricted",
+				HMACHeader:    "X-Signature",
+				HMACAlgorithm: HMACAlgorithmSHA256,
+				HMACMaxBody:   defaultHMACMaxBody,
+			},
+			CORS: ServerCORS{
+				AllowOrigins: []string{"*"},
+				AllowMethods: []string{http.MethodGet, http.MethodHead, http.MethodPut, http.MethodPatch, http.MethodPost, http.MethodDelete},
+				MaxAge:       600,
+			},
+			RateLimit: ServerRateLimit{
+				RequestsPerSecond: 10,
+				Burst:             20,
+				KeyBy:             RateLimitKeyIP,
+			},
 		},
 	}
 }
@@ -100,6 +176,32 @@ type ServerSettings struct {
 	EnableStatusPort bool `json:"enableStatusPort" required:"true" title:"Enable status port" description:"Status port notifies when server is up or down"`
 	EnableStopPort   bool `json:"enableStopPort" required:"true" title:"Enable stop port" description:"Stop port allows you to stop the server"`
 	EnableStartPort  bool `json:"enableStartPort" required:"true" title:"Enable start port" description:"Start port allows you to start the server"`
+
+	Routes             []ServerRoute `json:"routes" title:"Routes" description:"Each route gets its own request port. Leave empty to send all requests to the single Request port."`
+	EnableNotFoundPort bool          `json:"enableNotFoundPort" title:"Enable not found port" description:"Requests which do not match any route are sent to the Not found port instead of being answered with the not found status code"`
+	NotFoundStatusCode int           `json:"notFoundStatusCode" title:"Not found status code" description:"Status code for requests which do not match any route" minimum:"100" maximum:"599" default:"404"`
+
+	WebSocketPaths []string `json:"webSocketPaths" title:"WebSocket paths" description:"Path patterns where WebSocket upgrade requests are accepted, e.g. /ws or /rooms/:id"`
+
+	Auth ServerAuth `json:"auth" title:"Authentication" description:"Requests failing all enabled methods are rejected with 401"`
+	CORS ServerCORS `json:"cors" title:"CORS" description:"Cross-origin resource sharing"`
+
+	RateLimit ServerRateLimit `json:"rateLimit" title:"Rate limiting" description:"Clients exceeding their rate get 429, requests above in-flight limit get 503"`
+
+	MaxUploadSize      int64 `json:"maxUploadSize" title:"Max upload size" description:"Maximum size of multipart form request in bytes. Larger requests are rejected with 413. Zero means unlimited" minimum:"0"`
+	UploadsToTempFiles bool  `json:"uploadsToTempFiles" title:"Store uploads in temporary files" description:"Uploaded files are passed as paths to temporary files instead of base64 content"`
+
+	BodySchema  string `json:"bodySchema" title:"Body schema" format:"textarea" description:"JSON Schema every request body is validated against, unless route declares its own. Invalid requests are rejected with 400"`
+	QuerySchema string `json:"querySchema" title:"Query schema" format:"textarea" description:"JSON Schema object for query params, unless route declares its own"`
+
+	StaticMounts []ServerStaticMount `json:"staticMounts" title:"Static files" description:"Path prefixes served with static files directly by the server"`
+
+	OpenAPI ServerOpenAPI `json:"openAPI" title:"OpenAPI" description:"OpenAPI document generated from routes and their schemas"`
+
+	Cache ServerCache `json:"cache" title:"Cache" description:"Cache of GET responses"`
+
+	EnableAccessLogPort bool `json:"enableAccessLogPort" title:"Enable access log port" description:"Access log port emits every finished request in order. Entries are dropped while the flow is 1000 entries behind"`
+	MetricsInterval     int  `json:"metricsInterval" title:"Metrics interval" description:"Seconds between metrics aggregations shown on the dashboard" minimum:"1" default:"10"`
 }
 
 type ServerStartContext any
@@ -110,6 +212,10 @@ type ServerStart struct {
 	Hostnames    []string           `json:"hostnames" title:"Hostnames" required:"false" description:"List of virtual host this server should be bound to."` //requiredWhen:"['kind', 'equal', 'enum 1']"
 	ReadTimeout  int                `json:"readTimeout" required:"true" title:"Read Timeout" description:"Read timeout is the maximum duration for reading the entire request, including the body. A zero or negative value means there will be no timeout."`
 	WriteTimeout int                `json:"writeTimeout" required:"true" title:"Write Timeout" description:"Write timeout is the maximum duration before timing out writes of the response. It is reset whenever a new request's header is read."`
+	TLS          ServerTLS          `json:"tls" title:"TLS" description:"HTTPS settings"`
+	//
+	DrainTimeout    int `json:"drainTimeout" title:"Drain Timeout" description:"On stop, seconds in-flight requests are given to receive their responses. Requests still waiting after that are answered with drain status code." minimum:"0"`
+	DrainStatusCode int `json:"drainStatusCode" title:"Drain Status Code" description:"Status code for requests arrived or aborted while server stops" minimum:"100" maximum:"599" default:"503"`
 }
 
 type ServerRequest struct {
@@ -117,12 +223,15 @@ type ServerRequest struct {
 	RequestID     string             `json:"requestID" required:"true"`
 	RequestURI    string             `json:"requestURI" required:"true"`
 	RequestParams url.Values         `json:"requestParams" required:"true"`
+	PathParams    map[string]string  `json:"pathParams,omitempty" title:"Path params" description:"Params extracted from the route path pattern"`
 	Host          string             `json:"host" required:"true"`
 	Method        string             `json:"method" required:"true" title:"Method" enum:"GET,POST,PATCH,PUT,DELETE" enumTitles:"GET,POST,PATCH,PUT,DELETE"`
 	RealIP        string             `json:"realIP"`
 	Headers       []Header           `json:"headers,omitempty"`
 	Body          any                `json:"body"`
 	Scheme        string             `json:"scheme"`
+	ClientCert    string             `json:"clientCert,omitempty" title:"Client certificate" description:"Subject of the verified client certificate"`
+	Principal     *ServerPrincipal   `json:"principal,omitempty" title:"Principal" description:"Authenticated principal"`
 }
 
 type ServerStartControl struct {
@@ -131,18 +240,25 @@ type ServerStartControl struct {
 }
 
 type ServerStopControl struct {
-	Stop       bool     `json:"stop" format:"button" title:"Stop" required:"true" description:"Stop HTTP server"`
-	Status     string   `json:"status" title:"Status" readonly:"true"`
-	ListenAddr []string `json:"listenAddr" title:"Listen Address" readonly:"true"`
+	Stop       bool          `json:"stop" format:"button" title:"Stop" required:"true" description:"Stop HTTP server"`
+	Status     string        `json:"status" title:"Status" readonly:"true"`
+	ListenAddr []string      `json:"listenAddr" title:"Listen Address" readonly:"true"`
+	Metrics    ServerMetrics `json:"metrics" title:"Metrics" readonly:"true"`
 }
 
 type ServerStop struct {
 }
 
 type ServerStatus struct {
-	Context    ServerStartContext `json:"context" title:"Context"`
-	ListenAddr []string           `json:"listenAddr" title:"Listen Address" readonly:"true"`
-	IsRunning  bool               `json:"isRunning" title:"Is running" readonly:"true"`
+	Context     ServerStartContext `json:"context" title:"Context"`
+	ListenAddr  []string           `json:"listenAddr" title:"Listen Address" readonly:"true"`
+	IsRunning   bool               `json:"isRunning" title:"Is running" readonly:"true"`
+	RateLimited int64              `json:"rateLimited" title:"Rate limited" description:"Number of requests rejected with 429" readonly:"true"`
+	Overloaded  int64              `json:"overloaded" title:"Overloaded" description:"Number of requests rejected with 503 because of in-flight limit" readonly:"true"`
+	Drained     int64              `json:"drained" title:"Drained" description:"Number of in-flight requests answered by the flow while server was stopping" readonly:"true"`
+	Aborted     int64              `json:"aborted" title:"Aborted" description:"Number of in-flight requests answered with drain status code while server was stopping" readonly:"true"`
+	CacheHits   int64              `json:"cacheHits" title:"Cache hits" readonly:"true"`
+	CacheMisses int64              `json:"cacheMisses" title:"Cache misses" readonly:"true"`
 }
 
 type ServerResponseBody any
@@ -153,6 +269,12 @@ type ServerResponse struct {
 	ContentType ContentType        `json:"contentType" required:"true"`
 	Headers     []Header           `json:"headers"  title:"Response headers"`
 	Body        ServerResponseBody `json:"body" title:"Response body" configurable:"true"`
+	Stream      StreamMode         `json:"stream,omitempty"`
+	Event       string             `json:"event,omitempty" title:"Event" description:"Server-Sent Events event name"`
+	Close       bool               `json:"close,omitempty" title:"Close stream" description:"Ends the stream after this response is written"`
+	Base64      bool               `json:"base64,omitempty" title:"Base64 body" description:"Body is base64 encoded binary content, e.g. image or PDF"`
+	Filename    string             `json:"filename,omitempty" title:"Filename" description:"Sends body as a downloadable attachment with this filename"`
+	Redirect    string             `json:"redirect,omitempty" title:"Redirect URL" description:"Redirects client to this location. Status code should be one of 301, 302, 303, 307 or 308, 302 is used otherwise"`
 }
 
 type ContentType string
@@ -161,8 +283,7 @@ func (c ContentType) JSONSchema() (jsonschema.Schema, error) {
 	contentType := jsonschema.Schema{}
 	contentType.AddType(jsonschema.String)
 	contentType.WithTitle("Content Type").
-		WithDefault(200).
-		WithEnum(MIMEApplicationJSON, MIMEApplicationXML, MIMETextHTML, MimeTextPlain).
+		WithExamples(MIMEApplicationJSON, MIMEApplicationXML, MIMETextHTML, MimeTextPlain, "text/csv", "image/png", "application/pdf", MIMEOctetStream).
 		WithDefault(MIMEApplicationJSON).
 		WithDescription("Content type of the response")
 	return contentType, nil
@@ -214,6 +335,11 @@ func (h *Server) start(ctx context.Context, msg ServerStart, handler module.Hand
 	h.runLock.Lock()
 	defer h.runLock.Unlock()
 
+	tlsConfig, err := msg.TLS.tlsConfig(msg.Hostnames)
+	if err != nil {
+		return err
+	}
+
 	e := echo.New()
 	e.HideBanner = false
 	e.HidePort = false
@@ -222,10 +348,177 @@ func (h *Server) start(ctx context.Context, msg ServerStart, handler module.Hand
 
 	serverCtx, cancel := context.WithCancel(ctx)
 
+	h.metrics.reset()
+	accessLog := make(chan ServerAccessLog, accessLogQueueSize)
+	e.Use(h.accessLogMiddleware(msg, accessLog))
+	go h.sendAccessLog(serverCtx, accessLog, handler)
+
 	h.setCancelFunc(cancel)
 	h.contexts = ttlmap.New(ctx, msg.ReadTimeout*2)
 
+	h.rateLimitedCount.Store(0)
+	h.overloadedCount.Store(0)
+	limiter := newRateLimiter(serverCtx)
+
+	h.cacheHits.Store(0)
+	h.cacheMisses.Store(0)
+	cache := newResponseCache()
+
+	h.draining.Store(false)
+	h.drainedCount.Store(0)
+	h.abortedCount.Store(0)
+
+	drainStatusCode := msg.DrainStatusCode
+	if drainStatusCode == 0 {
+		drainStatusCode = http.StatusServiceUnavailable
+	}
+	// closed when drain timeout is over
+	abortCh := make(chan struct{})
+
 	e.Any("*", func(c echo.Context) error {
+		settings := h.getSettings()
+
+		if h.draining.Load() {
+			c.Response().Header().Set(echo.HeaderRetryAfter, retryAfter(time.Second))
+			return echo.NewHTTPError(drainStatusCode)
+		}
+
+		if settings.CORS.Enable {
+			// preflight requests carry no credentials, answer them before auth
+			if preflight, err := settings.CORS.handle(c); preflight {
+				return err
+			}
+		}
+
+		if settings.RateLimit.Enable {
+			key := rateLimitKey(settings.RateLimit, c.Request(), c.RealIP())
+			if ok, wait := limiter.allow(settings.RateLimit, key); !ok {
+				h.rateLimitedCount.Inc()
+				c.Response().Header().Set(echo.HeaderRetryAfter, retryAfter(wait))
+				return echo.NewHTTPError(http.StatusTooManyRequests)
+			}
+		}
+
+		staticMount, staticPath, isStatic := matchStaticMount(settings.StaticMounts, c.Request().URL.Path)
+		if isStatic && staticMount.Public {
+			if err := serveStatic(c, staticMount, staticPath, h.getSettingsTime()); !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+			// request is not for a static file, let the flow answer it
+			isStatic = false
+		}
+
+		isOpenAPI := settings.OpenAPI.match(c.Request())
+		if isOpenAPI && settings.OpenAPI.Public {
+			return h.serveOpenAPI(c, settings)
+		}
+
+		var principal *ServerPrincipal
+
+		if settings.Auth.isEnabled() {
+			p, err := settings.Auth.authenticate(c.Request())
+			if err != nil {
+				var maxBytesErr *http.MaxBytesError
+				if errors.As(err, &maxBytesErr) {
+					return echo.NewHTTPError(http.StatusRequestEntityTooLarge).SetInternal(err)
+				}
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error()).SetInternal(err)
+			}
+			if p == nil {
+				if challenge := settings.Auth.challenge(); challenge != "" {
+					c.Response().Header().Set(echo.HeaderWWWAuthenticate, challenge)
+				}
+				return echo.NewHTTPError(http.StatusUnauthorized)
+			}
+			principal = p
+		}
+
+		if isStatic {
+			if err := serveStatic(c, staticMount, staticPath, h.getSettingsTime()); !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+		}
+
+		if isOpenAPI {
+			return h.serveOpenAPI(c, settings)
+		}
+
+		if len(settings.WebSocketPaths) > 0 && websocket.IsWebSocketUpgrade(c.Request()) {
+			if params, ok := matchWebSocketPath(settings.WebSocketPaths, c.Request().URL.Path); ok {
+				return h.serveWebSocket(ctx, serverCtx, c, msg, handler, params, principal)
+			}
+		}
+
+		var capture *captureWriter
+
+		if settings.Cache.Enable && c.Request().Method == http.MethodGet && !requestBypassesCache(settings.Cache, c.Request()) {
+			key := cacheKey(settings.Cache, c.Request(), principal)
+			if entry, ok := cache.get(key); ok {
+				h.cacheHits.Inc()
+				return writeCachedResponse(c, entry)
+			}
+			h.cacheMisses.Inc()
+
+			capture = &captureWriter{ResponseWriter: c.Response().Writer, body: &bytes.Buffer{}}
+			c.Response().Writer = capture
+			c.Response().Header().Set(HeaderXCache, "MISS")
+
+			defer func() {
+				if !c.Response().Committed || capture.flushed || c.Response().Status != http.StatusOK {
+					return
+				}
+				ttl := responseTTL(settings.Cache, c.Request(), c.Response().Header(), principal)
+				if ttl <= 0 {
+					return
+				}
+				cache.put(&cacheEntry{
+					key:        key,
+					statusCode: c.Response().Status,
+					header:     c.Response().Header().Clone(),
+					body:       capture.body.Bytes(),
+					stored:     time.Now(),
+					expires:    time.Now().Add(ttl),
+				}, settings.Cache.MaxEntries)
+			}()
+		}
+
+		inFlight := h.inFlight.Inc()
+		defer h.inFlight.Dec()
+
+		if settings.RateLimit.Enable && settings.RateLimit.MaxInFlight > 0 && inFlight > int64(settings.RateLimit.MaxInFlight) {
+			h.overloadedCount.Inc()
+			c.Response().Header().Set(echo.HeaderRetryAfter, retryAfter(time.Second))
+			return echo.NewHTTPError(http.StatusServiceUnavailable)
+		}
+
+		requestPort := ServerRequestPort
+		var pathParams map[string]string
+
+		bodySchema, querySchema := settings.BodySchema, settings.QuerySchema
+
+		if len(settings.Routes) > 0 {
+			route, params, ok := resolveRoute(settings.Routes, c.Request().Method, c.Request().URL.Path)
+			switch {
+			case ok:
+				requestPort = getRoutePortName(route.Name)
+				pathParams = params
+				if route.BodySchema != "" {
+					bodySchema = route.BodySchema
+				}
+				if route.QuerySchema != "" {
+					querySchema = route.QuerySchema
+				}
+			case settings.EnableNotFoundPort:
+				requestPort = ServerNotFoundPort
+			default:
+				statusCode := settings.NotFoundStatusCode
+				if statusCode == 0 {
+					statusCode = http.StatusNotFound
+				}
+				return echo.NewHTTPError(statusCode)
+			}
+		}
+
 		id, err := uuid.NewUUID()
 		if err != nil {
 			return err
@@ -239,26 +532,15 @@ func (h *Server) start(ctx context.Context, msg ServerStart, handler module.Hand
 			Method:        c.Request().Method,
 			RequestURI:    c.Request().RequestURI,
 			RequestParams: c.QueryParams(),
+			PathParams:    pathParams,
 			RealIP:        c.RealIP(),
 			Scheme:        c.Scheme(),
-			Headers:       make([]Header, 0),
+			ClientCert:    clientCertSubject(c.Request()),
+			Principal:     principal,
+			Headers:       requestHeaders(c),
 		}
 		req := c.Request()
 
-		keys := make([]string, 0, len(req.Header))
-		for k := range req.Header {
-			keys = append(keys, k)
-		}
-		sort.Strings(keys)
-		for _, k := range keys {
-			for _, v := range req.Header[k] {
-				requestResult.Headers = append(requestResult.Headers, Header{
-					Key:   k,
-					Value: v,
-				})
-			}
-		}
-
 		cType := req.Header.Get(HeaderContentType)
 		switch {
 		case strings.HasPrefix(cType, MIMEApplicationJSON):
@@ -278,7 +560,15 @@ func (h *Server) start(ctx context.Context, msg ServerStart, handler module.Hand
 			}
 			requestResult.Body = m.Old()
 
-		case strings.HasPrefix(cType, MIMEApplicationForm), strings.HasPrefix(cType, MIMEMultipartForm):
+		case strings.HasPrefix(cType, MIMEMultipartForm):
+			body, tempFiles, err := parseMultipart(c, settings.MaxUploadSize, settings.UploadsToTempFiles)
+			if err != nil {
+				return err
+			}
+			defer removeFiles(tempFiles)
+			requestResult.Body = body
+
+		case strings.HasPrefix(cType, MIMEApplicationForm):
 			params, err := c.FormParams()
 			if err != nil {
 				return echo.NewHTTPError(http.StatusBadRequest, err.Error()).SetInternal(err)
@@ -289,14 +579,45 @@ func (h *Server) start(ctx context.Context, msg ServerStart, handler module.Hand
 			requestResult.Body = utils.BytesToString(body)
 		}
 
-		ch := make(chan ServerResponse)
-		h.contexts.Put(idStr, ch)
-		defer close(ch)
+		schemas := h.getSchemas()
+		bodyValidation, err := schemas.get(bodySchema)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error()).SetInternal(err)
+		}
+		queryValidation, err := schemas.get(querySchema)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error()).SetInternal(err)
+		}
+
+		validationErrors, err := validateRequest(bodyValidation, queryValidation, requestResult.Body, requestResult.RequestParams)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error()).SetInternal(err)
+		}
+		if len(validationErrors) > 0 {
+			return c.JSON(http.StatusBadRequest, ServerValidationErrors{
+				Message: "validation failed",
+				Errors:  validationErrors,
+			})
+		}
+
+		reqCtx := newRequestContext()
+		h.contexts.Put(idStr, reqCtx)
+
+		defer func() {
+			h.contexts.Delete(idStr)
+			close(reqCtx.done)
+		}()
 
 		doneCh := make(chan struct{})
 		go func() {
 			defer close(doneCh)
 
+			var streamMode StreamMode
+
+			readTimeout := time.Duration(msg.ReadTimeout) * time.Second
+			timer := time.NewTimer(readTimeout)
+			defer timer.Stop()
+
 			for {
 				select {
 				case <-c.Request().Context().Done():
@@ -305,32 +626,54 @@ func (h *Server) start(ctx context.Context, msg ServerStart, handler module.Hand
 				case <-ctx.Done():
 					return
 
-				case <-time.Tick(time.Duration(msg.ReadTimeout) * time.Second):
-					c.Error(fmt.Errorf("read timeout"))
+				case <-reqCtx.done:
 					return
 
-				case resp := <-ch:
-					for _, header := range resp.Headers {
-						c.Response().Header().Set(header.Key, header.Value)
+				case <-abortCh:
+					h.abortedCount.Inc()
+					if !streamMode.isStreaming() {
+						c.Response().Header().Set(echo.HeaderRetryAfter, retryAfter(time.Second))
+						c.Error(echo.NewHTTPError(drainStatusCode))
 					}
-					switch resp.ContentType {
-					case MIMEApplicationXML:
-						c.XML(resp.StatusCode, resp.Body)
-					case MIMEApplicationJSON:
-						c.JSON(resp.StatusCode, resp.Body)
-					case MIMETextHTML:
-						c.HTML(resp.StatusCode, fmt.Sprintf("%v", resp.Body))
-					case MimeTextPlain:
-						c.String(resp.StatusCode, fmt.Sprintf("%v", resp.Body))
-					default:
-						c.String(resp.StatusCode, fmt.Sprintf("%v", resp.Body))
+					return
+
+				case <-timer.C:
+					if !streamMode.isStreaming() {
+						c.Set(timedOutKey, true)
+						c.Error(fmt.Errorf("read timeout"))
 					}
 					return
+
+				case resp := <-reqCtx.responses:
+					if !streamMode.isStreaming() && !resp.Stream.isStreaming() {
+						if err := writeResponse(c, resp); err != nil {
+							c.Error(err)
+						}
+						if h.draining.Load() {
+							h.drainedCount.Inc()
+						}
+						return
+					}
+					if !streamMode.isStreaming() {
+						streamMode = resp.Stream
+						startStream(c, resp)
+					}
+					if err := writeStreamChunk(c, streamMode, resp); err != nil || resp.Close {
+						if h.draining.Load() {
+							h.drainedCount.Inc()
+						}
+						return
+					}
+					// next chunk is awaited for another read timeout
+					if !timer.Stop() {
+						<-timer.C
+					}
+					timer.Reset(readTimeout)
 				}
 			}
 		}()
 
-		if err = handler(c.Request().Context(), ServerRequestPort, requestResult); err != nil {
+		if err = handler(c.Request().Context(), requestPort, requestResult); err != nil {
 			return err
 		}
 		<-doneCh
@@ -340,6 +683,9 @@ func (h *Server) start(ctx context.Context, msg ServerStart, handler module.Hand
 	e.Server.ReadTimeout = time.Duration(msg.ReadTimeout) * time.Second
 	e.Server.WriteTimeout = time.Duration(msg.WriteTimeout) * time.Second
 
+	e.TLSServer.ReadTimeout = e.Server.ReadTimeout
+	e.TLSServer.WriteTimeout = e.Server.WriteTimeout
+
 	var (
 		listenPort      int
 		actualLocalPort int
@@ -366,7 +712,14 @@ func (h *Server) start(ctx context.Context, msg ServerStart, handler module.Hand
 	}
 
 	go func() {
-		err := e.Start(listenAddr)
+		var err error
+		if tlsConfig != nil {
+			e.TLSServer.Addr = listenAddr
+			e.TLSServer.TLSConfig = tlsConfig
+			err = e.StartServer(e.TLSServer)
+		} else {
+			err = e.Start(listenAddr)
+		}
 		if errors.Is(err, http.ErrServerClosed) {
 			return
 		}
@@ -375,8 +728,16 @@ func (h *Server) start(ctx context.Context, msg ServerStart, handler module.Hand
 
 	time.Sleep(time.Millisecond * 1500)
 
-	if e.Listener != nil {
-		if tcpAddr, ok := e.Listener.Addr().(*net.TCPAddr); ok {
+	scheme := "http"
+	addr := e.ListenerAddr()
+
+	if tlsConfig != nil {
+		scheme = "https"
+		addr = e.TLSListenerAddr()
+	}
+
+	if addr != nil {
+		if tcpAddr, ok := addr.(*net.TCPAddr); ok {
 			//
 
 			actualLocalPort = tcpAddr.Port
@@ -395,7 +756,7 @@ func (h *Server) start(ctx context.Context, msg ServerStart, handler module.Hand
 
 			publicURLs, err := h.client.ExposePort(exposeCtx, autoHostName, msg.Hostnames, tcpAddr.Port)
 			if err != nil {
-				h.setPublicListerAddr([]string{fmt.Sprintf("http://localhost:%d", tcpAddr.Port)})
+				h.setPublicListerAddr([]string{fmt.Sprintf("%s://localhost:%d", scheme, tcpAddr.Port)})
 			} else {
 				h.setPublicListerAddr(publicURLs)
 			}
@@ -406,12 +767,34 @@ func (h *Server) start(ctx context.Context, msg ServerStart, handler module.Hand
 	_ = h.sendStatus(ctx, msg.Context, handler)
 	// ask to reconcile (redraw the component)
 
+	go h.reportCounters(serverCtx, msg.Context, handler)
+
+	metricsInterval := h.getSettings().MetricsInterval
+	if metricsInterval <= 0 {
+		metricsInterval = 10
+	}
+	go h.reportMetrics(serverCtx, time.Duration(metricsInterval)*time.Second, handler)
+
 	<-serverCtx.Done()
 
+	h.draining.Store(true)
+
 	shutdownCtx, shutDownCancel := context.WithTimeout(context.Background(), time.Second*30)
 	defer shutDownCancel()
 
-	_ = e.Shutdown(shutdownCtx)
+	// stop listening while in-flight requests are drained
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		_ = e.Shutdown(shutdownCtx)
+	}()
+
+	h.drain(time.Duration(msg.DrainTimeout)*time.Second, abortCh)
+	<-shutdownDone
+
+	// hijacked connections are not tracked by shutdown
+	h.closeWebSockets()
+
 	h.setCancelFunc(nil)
 
 	//
@@ -427,6 +810,106 @@ func (h *Server) start(ctx context.Context, msg ServerStart, handler module.Hand
 	return h.startErr.Load()
 }
 
+func writeCachedResponse(c echo.Context, entry *cacheEntry) error {
+	header := c.Response().Header()
+	for k, v := range entry.header {
+		// headers of this request, e.g. CORS ones, take precedence
+		if _, ok := header[k]; !ok {
+			header[k] = v
+		}
+	}
+	header.Set(HeaderXCache, "HIT")
+	header.Set("Age", strconv.Itoa(int(time.Since(entry.stored).Seconds())))
+	c.Response().WriteHeader(entry.statusCode)
+	_, err := c.Response().Write(entry.body)
+	return err
+}
+
+func requestHeaders(c echo.Context) []Header {
+	req := c.Request()
+	headers := make([]Header, 0)
+
+	keys := make([]string, 0, len(req.Header))
+	for k := range req.Header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range req.Header[k] {
+			headers = append(headers, Header{
+				Key:   k,
+				Value: v,
+			})
+		}
+	}
+	return headers
+}
+
+func writeResponse(c echo.Context, resp ServerResponse) error {
+	for _, header := range resp.Headers {
+		c.Response().Header().Set(header.Key, header.Value)
+	}
+
+	if resp.Redirect != "" {
+		statusCode := resp.StatusCode
+		switch statusCode {
+		case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		default:
+			statusCode = http.StatusFound
+		}
+		return c.Redirect(statusCode, resp.Redirect)
+	}
+
+	if resp.Filename != "" {
+		c.Response().Header().Set(echo.HeaderContentDisposition, mime.FormatMediaType("attachment", map[string]string{
+			"filename": resp.Filename,
+		}))
+	}
+
+	if resp.Base64 {
+		body, err := base64.StdEncoding.DecodeString(fmt.Sprintf("%v", resp.Body))
+		if err != nil {
+			return fmt.Errorf("unable to decode base64 body: %v", err)
+		}
+		contentType := string(resp.ContentType)
+		if contentType == "" {
+			contentType = MIMEOctetStream
+		}
+		return c.Blob(resp.StatusCode, contentType, body)
+	}
+
+	switch resp.ContentType {
+	case MIMEApplicationXML:
+		return c.XML(resp.StatusCode, resp.Body)
+	case MIMEApplicationJSON:
+		return c.JSON(resp.StatusCode, resp.Body)
+	case MIMETextHTML:
+		return c.HTML(resp.StatusCode, fmt.Sprintf("%v", resp.Body))
+	case MimeTextPlain, "":
+		return c.String(resp.StatusCode, fmt.Sprintf("%v", resp.Body))
+	default:
+		return c.Blob(resp.StatusCode, string(resp.ContentType), []byte(fmt.Sprintf("%v", resp.Body)))
+	}
+}
+
+func (h *Server) getSettings() ServerSettings {
+	h.settingsLock.Lock()
+	defer h.settingsLock.Unlock()
+	return h.settings
+}
+
+func (h *Server) getSettingsTime() time.Time {
+	h.settingsLock.Lock()
+	defer h.settingsLock.Unlock()
+	return h.settingsTime
+}
+
+func (h *Server) getSchemas() serverSchemas {
+	h.settingsLock.Lock()
+	defer h.settingsLock.Unlock()
+	return h.schemas
+}
+
 func (h *Server) setPublicListerAddr(addr []string) {
 	h.publicListenAddrLock.Lock()
 	defer h.publicListenAddrLock.Unlock()
@@ -466,9 +949,27 @@ func (h *Server) Handle(ctx context.Context, handler module.Handler, port string
 		if !ok {
 			return fmt.Errorf("invalid settings message")
 		}
+		if err := validateRoutes(in.Routes); err != nil {
+			return err
+		}
+		if err := in.Auth.validate(); err != nil {
+			return err
+		}
+		if err := validateStaticMounts(in.StaticMounts); err != nil {
+			return err
+		}
+		if err := in.OpenAPI.validate(); err != nil {
+			return err
+		}
+		schemas, err := compileSchemas(in)
+		if err != nil {
+			return err
+		}
 
 		h.settingsLock.Lock()
 		h.settings = in
+		h.settingsTime = time.Now()
+		h.schemas = schemas
 		h.settingsLock.Unlock()
 
 	case ServerStartPort:
@@ -492,16 +993,30 @@ func (h *Server) Handle(ctx context.Context, handler module.Handler, port string
 			return fmt.Errorf("unknown request ID %s", in.RequestID)
 		}
 
-		ch := h.contexts.Get(in.RequestID)
-		if ch == nil {
+		reqCtx, ok := h.contexts.Get(in.RequestID).(*requestContext)
+		if !ok {
 			return fmt.Errorf("context '%s' not found", in.RequestID)
 		}
 
-		if respChannel, ok := ch.(chan ServerResponse); ok {
+		if reqCtx.accept(in) {
 			h.contexts.Delete(in.RequestID)
-			respChannel <- in
 		}
 
+		select {
+		case reqCtx.responses <- in:
+		case <-reqCtx.done:
+			return fmt.Errorf("request '%s' is already finished", in.RequestID)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+	case ServerWebSocketSendPort:
+		in, ok := msg.(ServerWebSocketSend)
+		if !ok {
+			return fmt.Errorf("invalid websocket send message")
+		}
+		return h.sendWebSocket(in)
+
 	default:
 		return fmt.Errorf("port %s is not supported", port)
 	}
@@ -514,6 +1029,7 @@ func (h *Server) getControl() interface{} {
 		return ServerStopControl{
 			Status:     "Running",
 			ListenAddr: h.getPublicListerAddr(),
+			Metrics:    h.metrics.getLast(),
 		}
 	}
 	return ServerStartControl{
@@ -539,12 +1055,6 @@ func (h *Server) Ports() []module.Port {
 			Configuration: h.settings,
 			Source:        true,
 		},
-		{
-			Name:          ServerRequestPort,
-			Label:         "Request",
-			Configuration: ServerRequest{},
-			Position:      module.Right,
-		},
 		{
 			Name:     ServerResponsePort,
 			Label:    "Response",
@@ -561,6 +1071,62 @@ func (h *Server) Ports() []module.Port {
 		},
 	}
 
+	if len(h.settings.Routes) == 0 {
+		ports = append(ports, module.Port{
+			Name:          ServerRequestPort,
+			Label:         "Request",
+			Configuration: ServerRequest{},
+			Position:      module.Right,
+		})
+	}
+
+	for _, route := range h.settings.Routes {
+		ports = append(ports, module.Port{
+			Name:          getRoutePortName(route.Name),
+			Label:         fmt.Sprintf("%s %s", route.Method, route.Path),
+			Configuration: ServerRequest{},
+			Position:      module.Right,
+		})
+	}
+
+	if len(h.settings.Routes) > 0 && h.settings.EnableNotFoundPort {
+		ports = append(ports, module.Port{
+			Name:          ServerNotFoundPort,
+			Label:         "Not found",
+			Configuration: ServerRequest{},
+			Position:      module.Right,
+		})
+	}
+
+	if len(h.settings.WebSocketPaths) > 0 {
+		ports = append(ports, module.Port{
+			Name:          ServerWebSocketConnectionPort,
+			Label:         "WS connection",
+			Configuration: ServerWebSocketConnection{},
+			Position:      module.Right,
+		}, module.Port{
+			Name:          ServerWebSocketMessagePort,
+			Label:         "WS message",
+			Configuration: ServerWebSocketMessage{},
+			Position:      module.Right,
+		}, module.Port{
+			Name:          ServerWebSocketSendPort,
+			Label:         "WS send",
+			Source:        true,
+			Configuration: ServerWebSocketSend{},
+			Position:      module.Right,
+		})
+	}
+
+	if h.settings.EnableAccessLogPort {
+		ports = append(ports, module.Port{
+			Name:          ServerAccessLogPort,
+			Label:         "Access log",
+			Configuration: ServerAccessLog{},
+			Position:      module.Bottom,
+		})
+	}
+
 	if h.settings.EnableStartPort {
 
 		ports = append(ports, module.Port{
@@ -599,22 +1165,49 @@ func (h *Server) Ports() []module.Port {
 
 func (h *Server) getStatus() ServerStatus {
 	return ServerStatus{
-		ListenAddr: h.getPublicListerAddr(),
-		IsRunning:  h.isRunning(),
+		ListenAddr:  h.getPublicListerAddr(),
+		IsRunning:   h.isRunning(),
+		RateLimited: h.rateLimitedCount.Load(),
+		Overloaded:  h.overloadedCount.Load(),
+		Drained:     h.drainedCount.Load(),
+		Aborted:     h.abortedCount.Load(),
+		CacheHits:   h.cacheHits.Load(),
+		CacheMisses: h.cacheMisses.Load(),
+	}
+}
+
+// reportCounters sends status when rejected request or cache counters change
+func (h *Server) reportCounters(ctx context.Context, start ServerStartContext, handler module.Handler) {
+	ticker := time.NewTicker(time.Second * 5)
+	defer ticker.Stop()
+
+	var last [4]int64
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			counters := [4]int64{h.rateLimitedCount.Load(), h.overloadedCount.Load(), h.cacheHits.Load(), h.cacheMisses.Load()}
+			if counters == last {
+				continue
+			}
+			last = counters
+			_ = h.sendStatus(ctx, start, handler)
+		}
 	}
 }
 
 func (h *Server) sendStatus(ctx context.Context, start ServerStartContext, handler module.Handler) error {
 	_ = handler(ctx, module.ReconcilePort, nil)
 
-	if !h.settings.EnableStatusPort {
+	if !h.getSettings().EnableStatusPort {
 		return nil
 	}
-	return handler(ctx, ServerStatusPort, ServerStatus{
-		Context:    start,
-		ListenAddr: h.getPublicListerAddr(),
-		IsRunning:  h.isRunning(),
-	})
+	status := h.getStatus()
+	status.Context = start
+
+	return handler(ctx, ServerStatusPort, status)
 }
 
 var _ module.Component = (*Server)(nil)
@@ -0,0 +1,167 @@
+package http
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"github.com/tiny-systems/module/module"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// testServerClient leaves port unexposed so server reports its local address
+type testServerClient struct{}
+
+func (testServerClient) ExposePort(context.Context, string, []string, int) ([]string, error) {
+	return nil, fmt.Errorf("not exposed")
+}
+
+func (testServerClient) DisclosePort(context.Context, int) error {
+	return nil
+}
+
+// startTestServer runs server until the test ends and returns its base URL, handler receives messages of output ports
+func startTestServer(t *testing.T, h *Server, start ServerStart, handler module.Handler) string {
+	t.Helper()
+
+	h.client = testServerClient{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- h.Handle(ctx, handler, ServerStartPort, start)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	deadline := time.Now().Add(time.Second * 5)
+	for time.Now().Before(deadline) {
+		if addr := h.getPublicListerAddr(); len(addr) > 0 && h.isRunning() {
+			return addr[0]
+		}
+		time.Sleep(time.Millisecond * 50)
+	}
+	t.Fatalf("server did not start: %v", h.startErr.Load())
+	return ""
+}
+
+// testServerStart is start message with short timeouts
+func testServerStart() ServerStart {
+	return ServerStart{
+		ReadTimeout:     2,
+		WriteTimeout:    2,
+		DrainTimeout:    1,
+		DrainStatusCode: 503,
+	}
+}
+
+func Test_writeResponse(t *testing.T) {
+	pdf := []byte("%PDF-1.4\x00\x01")
+
+	tests := []struct {
+		name            string
+		resp            ServerResponse
+		wantErr         bool
+		wantStatus      int
+		wantContentType string
+		wantHeaders     map[string]string
+		wantBody        string
+	}{
+		{
+			name:            "json",
+			resp:            ServerResponse{StatusCode: http.StatusCreated, ContentType: MIMEApplicationJSON, Body: map[string]any{"id": 1}},
+			wantStatus:      http.StatusCreated,
+			wantContentType: "application/json; charset=UTF-8",
+			wantBody:        "{\"id\":1}\n",
+		},
+		{
+			name:            "arbitrary content type",
+			resp:            ServerResponse{StatusCode: http.StatusOK, ContentType: "text/csv", Body: "a,b\n1,2"},
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/csv",
+			wantBody:        "a,b\n1,2",
+		},
+		{
+			name:            "base64 body",
+			resp:            ServerResponse{StatusCode: http.StatusOK, ContentType: "application/pdf", Base64: true, Body: base64.StdEncoding.EncodeToString(pdf)},
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/pdf",
+			wantBody:        string(pdf),
+		},
+		{
+			name:            "base64 body without content type",
+			resp:            ServerResponse{StatusCode: http.StatusOK, Base64: true, Body: base64.StdEncoding.EncodeToString(pdf)},
+			wantStatus:      http.StatusOK,
+			wantContentType: MIMEOctetStream,
+			wantBody:        string(pdf),
+		},
+		{
+			name:    "invalid base64 body",
+			resp:    ServerResponse{StatusCode: http.StatusOK, Base64: true, Body: "not base64!"},
+			wantErr: true,
+		},
+		{
+			name:            "attachment",
+			resp:            ServerResponse{StatusCode: http.StatusOK, ContentType: "application/pdf", Base64: true, Body: base64.StdEncoding.EncodeToString(pdf), Filename: "annual report.pdf"},
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/pdf",
+			wantHeaders:     map[string]string{echo.HeaderContentDisposition: `attachment; filename="annual report.pdf"`},
+			wantBody:        string(pdf),
+		},
+		{
+			name:        "redirect",
+			resp:        ServerResponse{StatusCode: http.StatusSeeOther, Redirect: "/done", Body: "ignored"},
+			wantStatus:  http.StatusSeeOther,
+			wantHeaders: map[string]string{echo.HeaderLocation: "/done"},
+		},
+		{
+			name:        "redirect with non redirect status",
+			resp:        ServerResponse{StatusCode: http.StatusOK, Redirect: "https://example.com/"},
+			wantStatus:  http.StatusFound,
+			wantHeaders: map[string]string{echo.HeaderLocation: "https://example.com/"},
+		},
+		{
+			name:            "custom headers",
+			resp:            ServerResponse{StatusCode: http.StatusAccepted, Headers: []Header{{Key: "X-Request-Id", Value: "42"}}, Body: "queued"},
+			wantStatus:      http.StatusAccepted,
+			wantContentType: "text/plain; charset=UTF-8",
+			wantHeaders:     map[string]string{"X-Request-Id": "42"},
+			wantBody:        "queued",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+
+			err := writeResponse(c, tt.resp)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("writeResponse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantContentType != "" {
+				if got := rec.Header().Get(HeaderContentType); got != tt.wantContentType {
+					t.Errorf("content type = %q, want %q", got, tt.wantContentType)
+				}
+			}
+			for k, v := range tt.wantHeaders {
+				if got := rec.Header().Get(k); got != v {
+					t.Errorf("header %s = %q, want %q", k, got, v)
+				}
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
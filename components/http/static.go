@@ -0,0 +1,149 @@
+package http
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+const staticIndexFile = "index.html"
+
+type ServerStaticFile struct {
+	Path        string `json:"path" required:"true" title:"Path" description:"Path relative to the mount prefix, e.g. index.html or css/app.css"`
+	ContentType string `json:"contentType" title:"Content type" description:"Detected from file extension if empty"`
+	Content     string `json:"content" title:"Content" format:"textarea"`
+	Base64      bool   `json:"base64" title:"Base64" description:"Content is base64 encoded binary data"`
+}
+
+type ServerStaticMount struct {
+	Prefix    string             `json:"prefix" required:"true" title:"Path prefix" description:"e.g. /ui. Non GET requests and requests for missing files go to the flow unless fallback file is set" minLength:"1"`
+	Directory string             `json:"directory" title:"Directory" description:"Local directory to serve files from. Files below are used if empty"`
+	Files     []ServerStaticFile `json:"files" title:"Files"`
+	Fallback  string             `json:"fallback" title:"Fallback file" description:"File served when requested one is not found, e.g. index.html for single page apps"`
+	Public    bool               `json:"public" title:"Public" description:"Serve files without authentication"`
+}
+
+// matchStaticMount finds mount serving the path, returns path relative to the mount
+func matchStaticMount(mounts []ServerStaticMount, p string) (ServerStaticMount, string, bool) {
+	for _, m := range mounts {
+		prefix := strings.TrimSuffix(m.Prefix, "/")
+		if p != prefix && prefix != "" && !strings.HasPrefix(p, prefix+"/") {
+			continue
+		}
+		rel := strings.TrimPrefix(strings.TrimPrefix(p, prefix), "/")
+		if rel == "" || strings.HasSuffix(rel, "/") {
+			rel += staticIndexFile
+		}
+		return m, rel, true
+	}
+	return ServerStaticMount{}, "", false
+}
+
+func validateStaticMounts(mounts []ServerStaticMount) error {
+	for _, m := range mounts {
+		if !strings.HasPrefix(m.Prefix, "/") {
+			return fmt.Errorf("static mount %s: prefix should start with /", m.Prefix)
+		}
+		for _, f := range m.Files {
+			if f.Path == "" {
+				return fmt.Errorf("static mount %s: file path can not be empty", m.Prefix)
+			}
+			if _, err := f.bytes(); err != nil {
+				return fmt.Errorf("static mount %s: file %s: %v", m.Prefix, f.Path, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (f ServerStaticFile) bytes() ([]byte, error) {
+	if !f.Base64 {
+		return []byte(f.Content), nil
+	}
+	return base64.StdEncoding.DecodeString(f.Content)
+}
+
+// serveStatic serves file of the mount with caching headers, modTime is used for files defined in settings.
+// Returns fs.ErrNotExist if nothing was served, such requests go to the flow
+func serveStatic(c echo.Context, mount ServerStaticMount, rel string, modTime time.Time) error {
+	req := c.Request()
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return fs.ErrNotExist
+	}
+
+	err := serveStaticFile(c, mount, rel, modTime)
+	if err != fs.ErrNotExist || mount.Fallback == "" {
+		return err
+	}
+	if err = serveStaticFile(c, mount, mount.Fallback, modTime); err == fs.ErrNotExist {
+		// misconfigured fallback
+		return echo.NewHTTPError(http.StatusNotFound)
+	}
+	return err
+}
+
+func serveStaticFile(c echo.Context, mount ServerStaticMount, rel string, modTime time.Time) error {
+	if mount.Directory != "" {
+		return serveDirectoryFile(c, mount.Directory, rel)
+	}
+
+	rel = strings.TrimPrefix(path.Clean("/"+rel), "/")
+	for _, f := range mount.Files {
+		if strings.TrimPrefix(path.Clean("/"+f.Path), "/") != rel {
+			continue
+		}
+		content, err := f.bytes()
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(content)
+
+		header := c.Response().Header()
+		header.Set("ETag", fmt.Sprintf(`"%s"`, hex.EncodeToString(sum[:16])))
+		setStaticContentType(c, rel, f.ContentType)
+
+		http.ServeContent(c.Response(), c.Request(), rel, modTime, bytes.NewReader(content))
+		return nil
+	}
+	return fs.ErrNotExist
+}
+
+func serveDirectoryFile(c echo.Context, dir string, rel string) error {
+	f, err := http.Dir(dir).Open("/" + rel)
+	if err != nil {
+		return fs.ErrNotExist
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if stat.IsDir() {
+		return serveDirectoryFile(c, dir, path.Join(rel, staticIndexFile))
+	}
+
+	c.Response().Header().Set("ETag", fmt.Sprintf(`W/"%x-%x"`, stat.Size(), stat.ModTime().UnixNano()))
+	setStaticContentType(c, rel, "")
+
+	http.ServeContent(c.Response(), c.Request(), stat.Name(), stat.ModTime(), f)
+	return nil
+}
+
+func setStaticContentType(c echo.Context, name string, contentType string) {
+	if contentType == "" {
+		contentType = mime.TypeByExtension(path.Ext(name))
+	}
+	if contentType != "" {
+		c.Response().Header().Set(HeaderContentType, contentType)
+	}
+}
@@ -0,0 +1,53 @@
+package http
+
+import (
+	"github.com/labstack/echo/v4"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_serveStatic(t *testing.T) {
+	root := ServerStaticMount{Prefix: "/", Files: []ServerStaticFile{{Path: "index.html", Content: "<h1>hi</h1>"}}}
+	spa := ServerStaticMount{Prefix: "/ui", Fallback: "index.html", Files: []ServerStaticFile{{Path: "index.html", Content: "<h1>app</h1>"}}}
+
+	tests := []struct {
+		name       string
+		mount      ServerStaticMount
+		method     string
+		path       string
+		wantStatus int
+		wantFlow   bool
+	}{
+		{name: "root index", mount: root, method: http.MethodGet, path: "/", wantStatus: http.StatusOK},
+		{name: "missing file goes to flow", mount: root, method: http.MethodGet, path: "/api/users", wantFlow: true},
+		{name: "post goes to flow", mount: root, method: http.MethodPost, path: "/index.html", wantFlow: true},
+		{name: "fallback", mount: spa, method: http.MethodGet, path: "/ui/deep/link", wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mount, rel, ok := matchStaticMount([]ServerStaticMount{tt.mount}, tt.path)
+			if !ok {
+				t.Fatalf("matchStaticMount() did not match %s", tt.path)
+			}
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(httptest.NewRequest(tt.method, tt.path, nil), rec)
+
+			err := serveStatic(c, mount, rel, time.Now())
+			if tt.wantFlow {
+				if err != fs.ErrNotExist {
+					t.Errorf("serveStatic() error = %v, want fs.ErrNotExist", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("serveStatic() error = %v", err)
+			}
+			if rec.Code != tt.wantStatus {
+				t.Errorf("serveStatic() status = %v, want %v", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
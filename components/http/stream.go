@@ -0,0 +1,137 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"github.com/swaggest/jsonschema-go"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	StreamModeNone    = "none"
+	StreamModeSSE     = "sse"
+	StreamModeChunked = "chunked"
+)
+
+const MIMETextEventStream = "text/event-stream"
+
+type StreamMode string
+
+func (s StreamMode) JSONSchema() (jsonschema.Schema, error) {
+	mode := jsonschema.Schema{}
+	mode.AddType(jsonschema.String)
+	mode.WithTitle("Stream").
+		WithEnum(StreamModeNone, StreamModeSSE, StreamModeChunked).
+		WithExtraPropertiesItem("enumTitles", []string{"None", "Server-Sent Events", "Chunked"}).
+		WithDefault(StreamModeNone).
+		WithDescription("Keeps request open so more responses with the same request ID can be written. Only the first response of the request sets the mode, status code and headers.")
+	return mode, nil
+}
+
+func (s StreamMode) isStreaming() bool {
+	return s == StreamModeSSE || s == StreamModeChunked
+}
+
+// requestContext connects pending HTTP request with responses coming to the response port
+type requestContext struct {
+	responses chan ServerResponse
+	done      chan struct{}
+
+	lock    *sync.Mutex
+	started bool
+	mode    StreamMode
+}
+
+func newRequestContext() *requestContext {
+	return &requestContext{
+		responses: make(chan ServerResponse),
+		done:      make(chan struct{}),
+		lock:      &sync.Mutex{},
+	}
+}
+
+// accept remembers stream mode of the first response and tells whether the response is the last one of the request
+func (r *requestContext) accept(resp ServerResponse) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if !r.started {
+		r.started = true
+		r.mode = resp.Stream
+	}
+	return !r.mode.isStreaming() || resp.Close
+}
+
+// startStream writes status and headers of the stream, consecutive chunks are written by writeStreamChunk
+func startStream(c echo.Context, resp ServerResponse) {
+	// streams live longer than write timeout allows
+	_ = http.NewResponseController(c.Response()).SetWriteDeadline(time.Time{})
+
+	header := c.Response().Header()
+	for _, h := range resp.Headers {
+		header.Set(h.Key, h.Value)
+	}
+
+	if resp.Stream == StreamModeSSE {
+		header.Set(HeaderContentType, MIMETextEventStream)
+		header.Set("Cache-Control", "no-cache")
+		header.Set("Connection", "keep-alive")
+	} else if resp.ContentType != "" {
+		header.Set(HeaderContentType, string(resp.ContentType))
+	}
+
+	statusCode := resp.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+	c.Response().WriteHeader(statusCode)
+	c.Response().Flush()
+}
+
+func writeStreamChunk(c echo.Context, mode StreamMode, resp ServerResponse) error {
+	if resp.Body == nil {
+		// close message may come without data
+		return nil
+	}
+
+	data, err := encodeStreamData(mode, resp)
+	if err != nil {
+		return err
+	}
+
+	if mode == StreamModeSSE {
+		b := strings.Builder{}
+		if resp.Event != "" {
+			b.WriteString(fmt.Sprintf("event: %s\n", resp.Event))
+		}
+		for _, line := range strings.Split(data, "\n") {
+			b.WriteString(fmt.Sprintf("data: %s\n", line))
+		}
+		b.WriteString("\n")
+		data = b.String()
+	}
+
+	if _, err = c.Response().Write([]byte(data)); err != nil {
+		return err
+	}
+	c.Response().Flush()
+	return nil
+}
+
+func encodeStreamData(mode StreamMode, resp ServerResponse) (string, error) {
+	if s, ok := resp.Body.(string); ok {
+		return s, nil
+	}
+	if resp.ContentType != MIMEApplicationJSON && mode != StreamModeSSE {
+		return fmt.Sprintf("%v", resp.Body), nil
+	}
+	b, err := json.Marshal(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("unable to encode stream chunk: %v", err)
+	}
+	return string(b), nil
+}
@@ -0,0 +1,151 @@
+package http
+
+import (
+	"context"
+	"github.com/labstack/echo/v4"
+	"github.com/tiny-systems/module/module"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_encodeStreamData(t *testing.T) {
+	tests := []struct {
+		name string
+		mode StreamMode
+		resp ServerResponse
+		want string
+	}{
+		{name: "string as is", mode: StreamModeSSE, resp: ServerResponse{Body: `{"a":1}`}, want: `{"a":1}`},
+		{name: "sse object as JSON", mode: StreamModeSSE, resp: ServerResponse{Body: map[string]any{"a": 1}}, want: `{"a":1}`},
+		{name: "chunked JSON", mode: StreamModeChunked, resp: ServerResponse{ContentType: MIMEApplicationJSON, Body: []any{1, "b"}}, want: `[1,"b"]`},
+		{name: "chunked text", mode: StreamModeChunked, resp: ServerResponse{ContentType: MimeTextPlain, Body: 42}, want: "42"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := encodeStreamData(tt.mode, tt.resp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("encodeStreamData() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := encodeStreamData(StreamModeSSE, ServerResponse{Body: map[string]any{"f": func() {}}}); err == nil {
+		t.Errorf("encodeStreamData() should fail on body which is not JSON")
+	}
+}
+
+func Test_writeStreamChunk(t *testing.T) {
+	tests := []struct {
+		name string
+		mode StreamMode
+		resp ServerResponse
+		want string
+	}{
+		{name: "sse data", mode: StreamModeSSE, resp: ServerResponse{Body: "hello"}, want: "data: hello\n\n"},
+		{name: "sse event", mode: StreamModeSSE, resp: ServerResponse{Event: "update", Body: map[string]any{"n": 1}}, want: "event: update\ndata: {\"n\":1}\n\n"},
+		{name: "sse multi-line data", mode: StreamModeSSE, resp: ServerResponse{Body: "one\ntwo\n"}, want: "data: one\ndata: two\ndata: \n\n"},
+		{name: "sse close without data", mode: StreamModeSSE, resp: ServerResponse{Close: true}, want: ""},
+		{name: "chunked text", mode: StreamModeChunked, resp: ServerResponse{Body: "line\n"}, want: "line\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+
+			if err := writeStreamChunk(c, tt.mode, tt.resp); err != nil {
+				t.Fatal(err)
+			}
+			if got := rec.Body.String(); got != tt.want {
+				t.Errorf("writeStreamChunk() wrote %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServer_streamExchange(t *testing.T) {
+	tests := []struct {
+		name            string
+		responses       []ServerResponse
+		wantContentType string
+		wantBody        string
+	}{
+		{
+			name: "sse",
+			responses: []ServerResponse{
+				{StatusCode: 200, Stream: StreamModeSSE, Event: "greeting", Body: "hello\nworld"},
+				// follow-up chunks carry default stream mode
+				{Stream: StreamModeNone, Body: map[string]any{"n": 1}},
+				{Stream: StreamModeNone, Close: true},
+			},
+			wantContentType: MIMETextEventStream,
+			wantBody:        "event: greeting\ndata: hello\ndata: world\n\ndata: {\"n\":1}\n\n",
+		},
+		{
+			name: "chunked",
+			responses: []ServerResponse{
+				{StatusCode: 200, Stream: StreamModeChunked, ContentType: MimeTextPlain, Body: "a"},
+				{Body: "b"},
+				{Body: "c", Close: true},
+			},
+			wantContentType: MimeTextPlain,
+			wantBody:        "abc",
+		},
+	}
+
+	h := (&Server{}).Instance().(*Server)
+
+	// responses are picked by request path
+	responses := make(map[string][]ServerResponse, len(tests))
+	for _, tt := range tests {
+		responses["/"+tt.name] = tt.responses
+	}
+	errCh := make(chan error, 10)
+
+	var handler module.Handler
+	handler = func(ctx context.Context, port string, data any) error {
+		req, ok := data.(ServerRequest)
+		if port != ServerRequestPort || !ok {
+			return nil
+		}
+		go func() {
+			for _, resp := range responses[req.RequestURI] {
+				resp.RequestID = req.RequestID
+				errCh <- h.Handle(context.Background(), handler, ServerResponsePort, resp)
+			}
+		}()
+		return nil
+	}
+	url := startTestServer(t, h, testServerStart(), handler)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get(url + "/" + tt.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			// body ends only when the close chunk reaches the client
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for range tt.responses {
+				if err = <-errCh; err != nil {
+					t.Errorf("Handle() error = %v", err)
+				}
+			}
+			if got := resp.Header.Get(HeaderContentType); got != tt.wantContentType {
+				t.Errorf("content type = %q, want %q", got, tt.wantContentType)
+			}
+			if string(body) != tt.wantBody {
+				t.Errorf("body = %q, want %q", body, tt.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package http
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type ServerTLS struct {
+	Enable             bool   `json:"enable" title:"Enable TLS" description:"Serve HTTPS instead of plain HTTP"`
+	SelfSigned         bool   `json:"selfSigned" title:"Self-signed certificate" description:"Generate self-signed certificate on start instead of using certificate and key below"`
+	Certificate        string `json:"certificate" title:"Certificate" format:"textarea" description:"PEM encoded certificate chain"`
+	Key                string `json:"key" title:"Private key" format:"textarea" description:"PEM encoded private key"`
+	ClientCA           string `json:"clientCA" title:"Client CA bundle" format:"textarea" description:"PEM encoded CA bundle. If set, clients are asked for a certificate signed by one of these CAs (mutual TLS)"`
+	ClientCertOptional bool   `json:"clientCertOptional" title:"Client certificate optional" description:"Accept clients without certificate. Presented certificates are still verified"`
+}
+
+// tlsConfig creates server TLS config, nil config means TLS is disabled
+func (t ServerTLS) tlsConfig(hostnames []string) (*tls.Config, error) {
+	if !t.Enable {
+		return nil, nil
+	}
+
+	var (
+		cert tls.Certificate
+		err  error
+	)
+
+	if t.SelfSigned {
+		cert, err = generateSelfSignedCertificate(hostnames)
+		if err != nil {
+			return nil, fmt.Errorf("unable to generate self-signed certificate: %v", err)
+		}
+	} else {
+		cert, err = tls.X509KeyPair([]byte(t.Certificate), []byte(t.Key))
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate or key: %v", err)
+		}
+	}
+
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+
+	if strings.TrimSpace(t.ClientCA) == "" {
+		return config, nil
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM([]byte(t.ClientCA)) {
+		return nil, fmt.Errorf("no valid certificates found in client CA bundle")
+	}
+	config.ClientCAs = pool
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+
+	if t.ClientCertOptional {
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return config, nil
+}
+
+func generateSelfSignedCertificate(hostnames []string) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"Tiny Systems"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+
+	for _, hostname := range hostnames {
+		host := hostname
+		if h, _, err := net.SplitHostPort(hostname); err == nil {
+			host = h
+		}
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+			continue
+		}
+		if host != "" {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+	}, nil
+}
+
+// clientCertSubject returns subject of the verified client certificate if any
+func clientCertSubject(req *http.Request) string {
+	if req.TLS == nil || len(req.TLS.VerifiedChains) == 0 || len(req.TLS.VerifiedChains[0]) == 0 {
+		return ""
+	}
+	return req.TLS.VerifiedChains[0][0].Subject.String()
+}
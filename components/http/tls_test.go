@@ -0,0 +1,215 @@
+package http
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// testKeyPair returns PEM encoded certificate and key signed by parent, self-signed if parent is nil
+func testKeyPair(t *testing.T, subject string, isCA bool, parent *tls.Certificate) (tls.Certificate, string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: subject},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+
+	signer, signerKey := template, any(key)
+	if parent != nil {
+		signer, signerKey = parent.Leaf, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert := tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+	return cert,
+		string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+}
+
+func TestServerTLS_tlsConfig(t *testing.T) {
+	_, certPEM, keyPEM := testKeyPair(t, "server", false, nil)
+	_, caPEM, _ := testKeyPair(t, "ca", true, nil)
+
+	tests := []struct {
+		name           string
+		tls            ServerTLS
+		wantNil        bool
+		wantErr        bool
+		wantClientAuth tls.ClientAuthType
+	}{
+		{name: "disabled", tls: ServerTLS{Certificate: certPEM, Key: keyPEM}, wantNil: true},
+		{name: "certificate and key", tls: ServerTLS{Enable: true, Certificate: certPEM, Key: keyPEM}},
+		{name: "self-signed", tls: ServerTLS{Enable: true, SelfSigned: true}},
+		{name: "invalid certificate", tls: ServerTLS{Enable: true, Certificate: "certificate", Key: keyPEM}, wantErr: true},
+		{name: "key of another certificate", tls: ServerTLS{Enable: true, Certificate: caPEM, Key: keyPEM}, wantErr: true},
+		{name: "empty client CA bundle", tls: ServerTLS{Enable: true, SelfSigned: true, ClientCA: " \n"}, wantClientAuth: tls.NoClientCert},
+		{name: "invalid client CA bundle", tls: ServerTLS{Enable: true, SelfSigned: true, ClientCA: "bundle"}, wantErr: true},
+		{name: "required client certificate", tls: ServerTLS{Enable: true, SelfSigned: true, ClientCA: caPEM}, wantClientAuth: tls.RequireAndVerifyClientCert},
+		{name: "optional client certificate", tls: ServerTLS{Enable: true, SelfSigned: true, ClientCA: caPEM, ClientCertOptional: true}, wantClientAuth: tls.VerifyClientCertIfGiven},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := tt.tls.tlsConfig(nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("tlsConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if (config == nil) != tt.wantNil {
+				t.Fatalf("tlsConfig() = %v, wantNil %v", config, tt.wantNil)
+			}
+			if config == nil {
+				return
+			}
+			if len(config.Certificates) != 1 {
+				t.Errorf("certificates = %d, want 1", len(config.Certificates))
+			}
+			if config.ClientAuth != tt.wantClientAuth {
+				t.Errorf("client auth = %v, want %v", config.ClientAuth, tt.wantClientAuth)
+			}
+		})
+	}
+}
+
+func Test_generateSelfSignedCertificate(t *testing.T) {
+	cert, err := generateSelfSignedCertificate([]string{"api.example.com:8443", "10.0.0.1", "[::2]:443", ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, host := range []string{"localhost", "api.example.com", "127.0.0.1", "10.0.0.1", "::2"} {
+		if err = leaf.VerifyHostname(host); err != nil {
+			t.Errorf("certificate is not valid for %s: %v", host, err)
+		}
+	}
+	if len(leaf.DNSNames) != 2 {
+		t.Errorf("DNS names = %v, want localhost and api.example.com only", leaf.DNSNames)
+	}
+}
+
+func TestServerTLS_clientCertificates(t *testing.T) {
+	ca, caPEM, _ := testKeyPair(t, "ca", true, nil)
+	client, _, _ := testKeyPair(t, "client", false, &ca)
+	other, _, _ := testKeyPair(t, "other", false, nil)
+
+	tests := []struct {
+		name        string
+		optional    bool
+		clientCert  *tls.Certificate
+		wantErr     bool
+		wantSubject string
+	}{
+		{name: "required and given", clientCert: &client, wantSubject: "CN=client"},
+		{name: "required and missing", wantErr: true},
+		{name: "required and unknown CA", clientCert: &other, wantErr: true},
+		{name: "optional and missing", optional: true},
+		{name: "optional and given", optional: true, clientCert: &client, wantSubject: "CN=client"},
+		{name: "optional and unknown CA", optional: true, clientCert: &other, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := ServerTLS{Enable: true, SelfSigned: true, ClientCA: caPEM, ClientCertOptional: tt.optional}.tlsConfig(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = io.WriteString(w, clientCertSubject(r))
+			}))
+			server.TLS = config
+			server.Config.ErrorLog = log.New(io.Discard, "", 0)
+			server.StartTLS()
+			defer server.Close()
+
+			clientConfig := &tls.Config{
+				InsecureSkipVerify: true,
+				// certificate is presented even when server does not list its CA
+				GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+					if tt.clientCert == nil {
+						return &tls.Certificate{}, nil
+					}
+					return tt.clientCert, nil
+				},
+			}
+			httpClient := &http.Client{Transport: &http.Transport{TLSClientConfig: clientConfig}}
+
+			resp, err := httpClient.Get(server.URL)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("request error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer resp.Body.Close()
+
+			subject, _ := io.ReadAll(resp.Body)
+			if string(subject) != tt.wantSubject {
+				t.Errorf("clientCertSubject() = %q, want %q", subject, tt.wantSubject)
+			}
+		})
+	}
+}
+
+func Test_clientCertSubject(t *testing.T) {
+	leaf := &x509.Certificate{Subject: pkix.Name{CommonName: "client", Organization: []string{"Acme"}}}
+
+	tests := []struct {
+		name string
+		tls  *tls.ConnectionState
+		want string
+	}{
+		{name: "plain HTTP"},
+		{name: "no verified chains", tls: &tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf}}},
+		{name: "verified chain", tls: &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{leaf}}}, want: "CN=client,O=Acme"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.TLS = tt.tls
+			if got := clientCertSubject(req); got != tt.want {
+				t.Errorf("clientCertSubject() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package http
+
+import (
+	"context"
+	"encoding/pem"
+	"github.com/tiny-systems/module/module"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientTransport_newHTTPClient(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	ca := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+
+	tests := []struct {
+		name           string
+		transport      ClientTransport
+		wantErr        bool
+		wantRequestErr bool
+	}{
+		{name: "untrusted certificate", transport: ClientTransport{}, wantRequestErr: true},
+		{name: "ca bundle", transport: ClientTransport{CACertificates: ca}},
+		{name: "skip verify", transport: ClientTransport{InsecureSkipVerify: true}},
+		{name: "invalid ca bundle", transport: ClientTransport{CACertificates: "certificate"}, wantErr: true},
+		{name: "invalid client certificate", transport: ClientTransport{ClientCertificate: ca}, wantErr: true},
+		{name: "invalid proxy", transport: ClientTransport{Proxy: "proxy"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := tt.transport.newHTTPClient()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newHTTPClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			resp, err := client.Get(server.URL)
+			if (err != nil) != tt.wantRequestErr {
+				t.Fatalf("request error = %v, wantRequestErr %v", err, tt.wantRequestErr)
+			}
+			if err == nil {
+				resp.Body.Close()
+			}
+		})
+	}
+}
+
+func TestClientTransport_redirects(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer other.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		case "/local":
+			http.Redirect(w, r, "/", http.StatusFound)
+		case "/external":
+			http.Redirect(w, r, other.URL, http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		transport  ClientTransport
+		path       string
+		wantStatus int
+		wantErr    bool
+	}{
+		{name: "follow", transport: ClientTransport{Redirects: RedirectFollow}, path: "/external", wantStatus: http.StatusOK},
+		{name: "none", transport: ClientTransport{Redirects: RedirectNone}, path: "/local", wantStatus: http.StatusFound},
+		{name: "same host", transport: ClientTransport{Redirects: RedirectSameHost}, path: "/local", wantStatus: http.StatusOK},
+		{name: "same host external", transport: ClientTransport{Redirects: RedirectSameHost}, path: "/external", wantStatus: http.StatusFound},
+		{name: "max redirects", transport: ClientTransport{Redirects: RedirectFollow, MaxRedirects: 3}, path: "/loop", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := tt.transport.newHTTPClient()
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Get(server.URL + tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("request error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestClient_transportSettings(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+	}))
+	defer proxy.Close()
+
+	client := (&Client{}).Instance().(*Client)
+	handler := func(ctx context.Context, port string, data any) error {
+		return nil
+	}
+
+	if err := client.Handle(context.Background(), handler, module.SettingsPort, ClientRequestSettings{Transport: ClientTransport{Proxy: "proxy"}}); err == nil {
+		t.Fatalf("invalid transport settings should be rejected")
+	}
+	if err := client.Handle(context.Background(), handler, module.SettingsPort, ClientRequestSettings{Transport: ClientTransport{Proxy: proxy.URL}}); err != nil {
+		t.Fatal(err)
+	}
+	first := client.httpClient()
+
+	err := client.Handle(context.Background(), handler, ClientRequestPort, ClientRequest{
+		Request: ClientRequestRequest{Method: http.MethodGet, URL: "http://api.internal/items"},
+	})
+	if err != nil {
+		t.Fatalf("Handle() error = %v", err)
+	}
+	if proxied != "http://api.internal/items" {
+		t.Errorf("proxy got %q, want request sent through the proxy", proxied)
+	}
+	if client.httpClient() != first {
+		t.Errorf("client should be shared between requests")
+	}
+}
@@ -0,0 +1,232 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/getkin/kin-openapi/openapi3"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	ValidationLocationBody  = "body"
+	ValidationLocationQuery = "query"
+)
+
+type ServerValidationError struct {
+	Location string `json:"location"`
+	Path     string `json:"path"`
+	Message  string `json:"message"`
+}
+
+type ServerValidationErrors struct {
+	Message string                  `json:"message"`
+	Errors  []ServerValidationError `json:"errors"`
+}
+
+// schema definitions are moved to components of a generated document so the loader resolves refs to them
+const rootSchemaName = "_root"
+
+// serverSchemas are compiled schemas of settings by their source
+type serverSchemas map[string]*openapi3.Schema
+
+// compileSchemas compiles all schemas of the settings
+func compileSchemas(settings ServerSettings) (serverSchemas, error) {
+	schemas := make(serverSchemas)
+
+	add := func(name string, source string) error {
+		if strings.TrimSpace(source) == "" {
+			return nil
+		}
+		if _, ok := schemas[source]; ok {
+			return nil
+		}
+		schema, err := compileSchema(source)
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		schemas[source] = schema
+		return nil
+	}
+
+	if err := add("body schema", settings.BodySchema); err != nil {
+		return nil, err
+	}
+	if err := add("query schema", settings.QuerySchema); err != nil {
+		return nil, err
+	}
+	for _, route := range settings.Routes {
+		if err := add(fmt.Sprintf("route %s body schema", route.Name), route.BodySchema); err != nil {
+			return nil, err
+		}
+		if err := add(fmt.Sprintf("route %s query schema", route.Name), route.QuerySchema); err != nil {
+			return nil, err
+		}
+	}
+	return schemas, nil
+}
+
+// get returns compiled schema, nil if source is empty
+func (s serverSchemas) get(source string) (*openapi3.Schema, error) {
+	if strings.TrimSpace(source) == "" {
+		return nil, nil
+	}
+	if schema, ok := s[source]; ok {
+		return schema, nil
+	}
+	// settings changed in between
+	return compileSchema(source)
+}
+
+// compileSchema parses JSON Schema and resolves its local refs to definitions or $defs
+func compileSchema(source string) (*openapi3.Schema, error) {
+	var root map[string]any
+	if err := json.Unmarshal([]byte(source), &root); err != nil {
+		return nil, fmt.Errorf("invalid schema: %v", err)
+	}
+
+	components := make(map[string]any)
+	for _, key := range []string{"definitions", "$defs"} {
+		defs, ok := root[key].(map[string]any)
+		if !ok {
+			continue
+		}
+		for name, def := range defs {
+			components[name] = def
+		}
+		delete(root, key)
+	}
+	components[rootSchemaName] = root
+
+	data, err := json.Marshal(map[string]any{
+		"openapi": openAPIVersion,
+		"info":    map[string]any{"title": "schema", "version": "1"},
+		"paths":   map[string]any{},
+		"components": map[string]any{
+			"schemas": components,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, prefix := range []string{"#/definitions/", "#/$defs/"} {
+		data = bytes.ReplaceAll(data, []byte(prefix), []byte("#/components/schemas/"))
+	}
+
+	doc, err := openapi3.NewLoader().LoadFromData(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema: %v", err)
+	}
+	return doc.Components.Schemas[rootSchemaName].Value, nil
+}
+
+// validateRequest checks body and query params against schemas, nil schema skips the check
+func validateRequest(bodySchema *openapi3.Schema, querySchema *openapi3.Schema, body any, query url.Values) ([]ServerValidationError, error) {
+	var result []ServerValidationError
+
+	if bodySchema != nil {
+		// bring body to plain JSON types whatever parser produced it
+		value, err := toJSONValue(body)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, schemaErrors(ValidationLocationBody, bodySchema.VisitJSON(value, openapi3.MultiErrors()))...)
+	}
+
+	if querySchema != nil {
+		value := queryToJSONValue(querySchema, query)
+		result = append(result, schemaErrors(ValidationLocationQuery, querySchema.VisitJSON(value, openapi3.MultiErrors()))...)
+	}
+
+	return result, nil
+}
+
+func toJSONValue(v any) (any, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var value any
+	if err = json.Unmarshal(b, &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// queryToJSONValue converts query params to types declared in the schema properties
+func queryToJSONValue(schema *openapi3.Schema, query url.Values) map[string]any {
+	value := make(map[string]any, len(query))
+	for k, v := range query {
+		if len(v) == 0 {
+			continue
+		}
+		var prop *openapi3.Schema
+		if ref, ok := schema.Properties[k]; ok && ref != nil {
+			prop = ref.Value
+		}
+		if prop != nil && prop.Type.Is(openapi3.TypeArray) {
+			var items *openapi3.Schema
+			if prop.Items != nil {
+				items = prop.Items.Value
+			}
+			list := make([]any, len(v))
+			for i, item := range v {
+				list[i] = coerceQueryValue(items, item)
+			}
+			value[k] = list
+			continue
+		}
+		value[k] = coerceQueryValue(prop, v[0])
+	}
+	return value
+}
+
+func coerceQueryValue(schema *openapi3.Schema, v string) any {
+	if schema == nil {
+		return v
+	}
+	switch {
+	case schema.Type.Is(openapi3.TypeInteger), schema.Type.Is(openapi3.TypeNumber):
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f
+		}
+	case schema.Type.Is(openapi3.TypeBoolean):
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+	return v
+}
+
+func schemaErrors(location string, err error) []ServerValidationError {
+	if err == nil {
+		return nil
+	}
+
+	var multi openapi3.MultiError
+	if errors.As(err, &multi) {
+		var result []ServerValidationError
+		for _, e := range multi {
+			result = append(result, schemaErrors(location, e)...)
+		}
+		return result
+	}
+
+	var schemaErr *openapi3.SchemaError
+	if errors.As(err, &schemaErr) {
+		return []ServerValidationError{{
+			Location: location,
+			Path:     "/" + strings.Join(schemaErr.JSONPointer(), "/"),
+			Message:  schemaErr.Reason,
+		}}
+	}
+
+	return []ServerValidationError{{
+		Location: location,
+		Path:     "/",
+		Message:  err.Error(),
+	}}
+}
@@ -0,0 +1,124 @@
+package http
+
+import (
+	"net/url"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func Test_validateRequest(t *testing.T) {
+	bodySchema := `{
+		"type": "object",
+		"required": ["name"],
+		"properties": {
+			"name": {"type": "string", "minLength": 1},
+			"age": {"type": "integer", "minimum": 0}
+		}
+	}`
+	querySchema := `{
+		"type": "object",
+		"properties": {
+			"limit": {"type": "integer", "maximum": 100},
+			"tags": {"type": "array", "items": {"type": "string"}}
+		}
+	}`
+
+	tests := []struct {
+		name      string
+		body      any
+		query     url.Values
+		wantPaths []string
+	}{
+		{
+			name:  "valid",
+			body:  map[string]any{"name": "john", "age": 30},
+			query: url.Values{"limit": {"10"}, "tags": {"a", "b"}},
+		},
+		{
+			name:      "missing required and wrong type",
+			body:      map[string]any{"age": "old"},
+			query:     url.Values{},
+			wantPaths: []string{"body:/name", "body:/age"},
+		},
+		{
+			name:      "query param above maximum",
+			body:      map[string]any{"name": "john"},
+			query:     url.Values{"limit": {"1000"}},
+			wantPaths: []string{"query:/limit"},
+		},
+		{
+			name:      "body is not an object",
+			body:      "plain text",
+			wantPaths: []string{"body:/"},
+		},
+	}
+	body, err := compileSchema(bodySchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query, err := compileSchema(querySchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs, err := validateRequest(body, query, tt.body, tt.query)
+			if err != nil {
+				t.Fatalf("validateRequest() error = %v", err)
+			}
+			var paths []string
+			for _, e := range errs {
+				paths = append(paths, e.Location+":"+e.Path)
+			}
+			if !reflect.DeepEqual(sortedStrings(paths), sortedStrings(tt.wantPaths)) {
+				t.Errorf("validateRequest() errors = %v, want %v", errs, tt.wantPaths)
+			}
+		})
+	}
+}
+
+func Test_compileSchemaRefs(t *testing.T) {
+	source := `{
+		"type": "object",
+		"properties": {
+			"owner": {"$ref": "#/definitions/user"},
+			"members": {"type": "array", "items": {"$ref": "#/$defs/user"}}
+		},
+		"definitions": {
+			"user": {"type": "object", "required": ["name"], "properties": {"name": {"type": "string"}}}
+		},
+		"$defs": {
+			"user": {"type": "object", "required": ["name"], "properties": {"name": {"type": "string"}}}
+		}
+	}`
+	schema, err := compileSchema(source)
+	if err != nil {
+		t.Fatalf("compileSchema() error = %v", err)
+	}
+
+	errs, err := validateRequest(schema, nil, map[string]any{
+		"owner":   map[string]any{"name": "john"},
+		"members": []any{map[string]any{}},
+	}, nil)
+	if err != nil {
+		t.Fatalf("validateRequest() error = %v", err)
+	}
+	if len(errs) != 1 || errs[0].Path != "/members/0/name" {
+		t.Errorf("validateRequest() errors = %v, want missing /members/0/name", errs)
+	}
+
+	if _, err = compileSchema(`{"properties": {"owner": {"$ref": "#/definitions/missing"}}}`); err == nil {
+		t.Errorf("compileSchema() should reject unresolved refs")
+	}
+}
+
+func sortedStrings(s []string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+	out := append([]string{}, s...)
+	sort.Strings(out)
+	return out
+}
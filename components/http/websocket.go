@@ -0,0 +1,245 @@
+package http
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+	"github.com/tiny-systems/module/module"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	ServerWebSocketMessagePort    = "ws_message"
+	ServerWebSocketConnectionPort = "ws_connection"
+	ServerWebSocketSendPort       = "ws_send"
+)
+
+const (
+	WebSocketEventOpen  = "open"
+	WebSocketEventClose = "close"
+)
+
+const webSocketWriteTimeout = time.Second * 10
+
+type ServerWebSocketConnection struct {
+	Context      ServerStartContext `json:"context"`
+	ConnectionID string             `json:"connectionID" required:"true" title:"Connection ID"`
+	Event        string             `json:"event" required:"true" title:"Event" enum:"open,close" enumTitles:"Open,Close"`
+	Path         string             `json:"path" title:"Path"`
+	PathParams   map[string]string  `json:"pathParams,omitempty" title:"Path params"`
+	RealIP       string             `json:"realIP"`
+	Headers      []Header           `json:"headers,omitempty"`
+	Principal    *ServerPrincipal   `json:"principal,omitempty" title:"Principal" description:"Authenticated principal"`
+}
+
+type ServerWebSocketMessage struct {
+	Context      ServerStartContext `json:"context"`
+	ConnectionID string             `json:"connectionID" required:"true" title:"Connection ID"`
+	Binary       bool               `json:"binary" title:"Binary" description:"Binary frames are base64 encoded"`
+	Data         any                `json:"data" title:"Data" description:"Text frames containing JSON are decoded"`
+}
+
+type ServerWebSocketSend struct {
+	ConnectionID string `json:"connectionID" title:"Connection ID" description:"Connection to send data to. Ignored when broadcasting"`
+	Broadcast    bool   `json:"broadcast" title:"Broadcast" description:"Send data to all open connections"`
+	Binary       bool   `json:"binary" title:"Binary" description:"Send base64 encoded data as binary frame"`
+	Close        bool   `json:"close" title:"Close" description:"Close connection after data is sent"`
+	Data         any    `json:"data" title:"Data" configurable:"true"`
+}
+
+// wsConnection guards concurrent writes, gorilla connections support only one writer at a time
+type wsConnection struct {
+	conn      *websocket.Conn
+	writeLock *sync.Mutex
+}
+
+func (w *wsConnection) write(messageType int, data []byte) error {
+	w.writeLock.Lock()
+	defer w.writeLock.Unlock()
+
+	_ = w.conn.SetWriteDeadline(time.Now().Add(webSocketWriteTimeout))
+	return w.conn.WriteMessage(messageType, data)
+}
+
+func (w *wsConnection) close() error {
+	w.writeLock.Lock()
+	defer w.writeLock.Unlock()
+
+	_ = w.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	return w.conn.Close()
+}
+
+// matchWebSocketPath finds configured websocket path matching the request path
+func matchWebSocketPath(paths []string, path string) (map[string]string, bool) {
+	for _, p := range paths {
+		if params, ok := matchRoute(ServerRoute{Path: p}, "", path); ok {
+			return params, true
+		}
+	}
+	return nil, false
+}
+
+// serveWebSocket runs read loop of the connection, serverCtx closes the connection when server stops
+func (h *Server) serveWebSocket(ctx context.Context, serverCtx context.Context, c echo.Context, start ServerStart, handler module.Handler, pathParams map[string]string, principal *ServerPrincipal) error {
+	upgrader := websocket.Upgrader{}
+
+	if cors := h.getSettings().CORS; cors.Enable {
+		upgrader.CheckOrigin = func(r *http.Request) bool {
+			origin := r.Header.Get(echo.HeaderOrigin)
+			return origin == "" || cors.allowOrigin(origin) != ""
+		}
+	}
+
+	conn, err := upgrader.Upgrade(c.Response(), c.Request(), nil)
+	if err != nil {
+		// upgrader already replied with an error
+		return nil
+	}
+
+	id, err := uuid.NewUUID()
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	idStr := id.String()
+
+	wsConn := &wsConnection{
+		conn:      conn,
+		writeLock: &sync.Mutex{},
+	}
+
+	h.wsConnections.Set(idStr, wsConn)
+
+	closedCh := make(chan struct{})
+	defer close(closedCh)
+
+	go func() {
+		// hijacked connections are not closed by server shutdown
+		select {
+		case <-serverCtx.Done():
+		case <-closedCh:
+		}
+		_ = wsConn.close()
+	}()
+
+	event := ServerWebSocketConnection{
+		Context:      start.Context,
+		ConnectionID: idStr,
+		Event:        WebSocketEventOpen,
+		Path:         c.Request().URL.Path,
+		PathParams:   pathParams,
+		RealIP:       c.RealIP(),
+		Headers:      requestHeaders(c),
+		Principal:    principal,
+	}
+
+	if err = handler(ctx, ServerWebSocketConnectionPort, event); err != nil {
+		h.wsConnections.Remove(idStr)
+		return nil
+	}
+
+	defer func() {
+		h.wsConnections.Remove(idStr)
+		event.Event = WebSocketEventClose
+		_ = handler(ctx, ServerWebSocketConnectionPort, event)
+	}()
+
+	for {
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			return nil
+		}
+
+		msg := ServerWebSocketMessage{
+			Context:      start.Context,
+			ConnectionID: idStr,
+		}
+
+		if messageType == websocket.BinaryMessage {
+			msg.Binary = true
+			msg.Data = base64.StdEncoding.EncodeToString(data)
+		} else {
+			var v any
+			if err = json.Unmarshal(data, &v); err != nil {
+				v = string(data)
+			}
+			msg.Data = v
+		}
+
+		_ = handler(ctx, ServerWebSocketMessagePort, msg)
+	}
+}
+
+// closeWebSockets closes and forgets all open connections
+func (h *Server) closeWebSockets() {
+	for _, id := range h.wsConnections.Keys() {
+		if conn, ok := h.wsConnections.Pop(id); ok {
+			_ = conn.close()
+		}
+	}
+}
+
+func (h *Server) sendWebSocket(in ServerWebSocketSend) error {
+	messageType := websocket.TextMessage
+
+	var (
+		data []byte
+		err  error
+	)
+
+	switch v := in.Data.(type) {
+	case nil:
+	case string:
+		data = []byte(v)
+		if in.Binary {
+			messageType = websocket.BinaryMessage
+			if data, err = base64.StdEncoding.DecodeString(v); err != nil {
+				return fmt.Errorf("unable to decode binary data: %v", err)
+			}
+		}
+	default:
+		if data, err = json.Marshal(v); err != nil {
+			return fmt.Errorf("unable to encode data: %v", err)
+		}
+	}
+
+	if !in.Broadcast {
+		conn, ok := h.wsConnections.Get(in.ConnectionID)
+		if !ok {
+			return fmt.Errorf("connection '%s' not found", in.ConnectionID)
+		}
+		return writeWebSocket(conn, messageType, data, in.Close)
+	}
+
+	ids := h.wsConnections.Keys()
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		conn, ok := h.wsConnections.Get(id)
+		if !ok {
+			continue
+		}
+		// broken connections will be removed by its read loop
+		_ = writeWebSocket(conn, messageType, data, in.Close)
+	}
+	return nil
+}
+
+func writeWebSocket(conn *wsConnection, messageType int, data []byte, closeAfter bool) error {
+	if data != nil {
+		if err := conn.write(messageType, data); err != nil {
+			return err
+		}
+	}
+	if closeAfter {
+		return conn.close()
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package http
+
+import (
+	"context"
+	"encoding/base64"
+	"github.com/gorilla/websocket"
+	"github.com/tiny-systems/module/module"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_matchWebSocketPath(t *testing.T) {
+	paths := []string{"/ws", "/rooms/:id"}
+
+	tests := []struct {
+		name       string
+		path       string
+		wantParams map[string]string
+		wantOk     bool
+	}{
+		{name: "static path", path: "/ws", wantParams: map[string]string{}, wantOk: true},
+		{name: "path param", path: "/rooms/42", wantParams: map[string]string{"id": "42"}, wantOk: true},
+		{name: "no match", path: "/rooms"},
+		{name: "too long", path: "/ws/1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, ok := matchWebSocketPath(paths, tt.path)
+			if ok != tt.wantOk {
+				t.Fatalf("matchWebSocketPath() ok = %v, want %v", ok, tt.wantOk)
+			}
+			if ok && !reflect.DeepEqual(params, tt.wantParams) {
+				t.Errorf("matchWebSocketPath() params = %v, want %v", params, tt.wantParams)
+			}
+		})
+	}
+}
+
+func TestServer_webSocket(t *testing.T) {
+	h := (&Server{}).Instance().(*Server)
+	settings := h.settings
+	settings.WebSocketPaths = []string{"/rooms/:id"}
+	if err := h.Handle(context.Background(), nil, module.SettingsPort, settings); err != nil {
+		t.Fatal(err)
+	}
+
+	events := make(chan ServerWebSocketConnection, 10)
+	messages := make(chan ServerWebSocketMessage, 10)
+
+	handler := func(ctx context.Context, port string, data any) error {
+		switch port {
+		case ServerWebSocketConnectionPort:
+			events <- data.(ServerWebSocketConnection)
+		case ServerWebSocketMessagePort:
+			messages <- data.(ServerWebSocketMessage)
+		}
+		return nil
+	}
+	url := "ws" + strings.TrimPrefix(startTestServer(t, h, testServerStart(), handler), "http") + "/rooms/7"
+
+	dial := func() (*websocket.Conn, string) {
+		t.Helper()
+		conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			_ = conn.Close()
+		})
+		event := receive(t, events)
+		if event.Event != WebSocketEventOpen {
+			t.Fatalf("event = %s, want open", event.Event)
+		}
+		if event.PathParams["id"] != "7" {
+			t.Errorf("path params = %v, want id 7", event.PathParams)
+		}
+		return conn, event.ConnectionID
+	}
+
+	read := func(conn *websocket.Conn) (int, string) {
+		t.Helper()
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second * 2))
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return messageType, string(data)
+	}
+
+	first, firstID := dial()
+	second, secondID := dial()
+
+	t.Run("incoming frames", func(t *testing.T) {
+		if err := first.WriteMessage(websocket.TextMessage, []byte(`{"a":1}`)); err != nil {
+			t.Fatal(err)
+		}
+		msg := receive(t, messages)
+		if msg.ConnectionID != firstID || !reflect.DeepEqual(msg.Data, map[string]any{"a": float64(1)}) {
+			t.Errorf("message = %+v, want decoded JSON of the first connection", msg)
+		}
+
+		if err := first.WriteMessage(websocket.TextMessage, []byte("hi")); err != nil {
+			t.Fatal(err)
+		}
+		if msg = receive(t, messages); msg.Data != "hi" {
+			t.Errorf("data = %v, want plain text", msg.Data)
+		}
+
+		if err := first.WriteMessage(websocket.BinaryMessage, []byte{0, 1, 2}); err != nil {
+			t.Fatal(err)
+		}
+		if msg = receive(t, messages); !msg.Binary || msg.Data != base64.StdEncoding.EncodeToString([]byte{0, 1, 2}) {
+			t.Errorf("message = %+v, want base64 encoded binary", msg)
+		}
+	})
+
+	t.Run("unknown connection", func(t *testing.T) {
+		if err := h.sendWebSocket(ServerWebSocketSend{ConnectionID: "unknown", Data: "hi"}); err == nil {
+			t.Errorf("sendWebSocket() should fail for unknown connection")
+		}
+	})
+
+	t.Run("send to connection", func(t *testing.T) {
+		if err := h.sendWebSocket(ServerWebSocketSend{ConnectionID: secondID, Data: map[string]any{"b": 2}}); err != nil {
+			t.Fatal(err)
+		}
+		if messageType, data := read(second); messageType != websocket.TextMessage || data != `{"b":2}` {
+			t.Errorf("received %d %q, want JSON text frame", messageType, data)
+		}
+	})
+
+	t.Run("binary", func(t *testing.T) {
+		if err := h.sendWebSocket(ServerWebSocketSend{ConnectionID: firstID, Binary: true, Data: base64.StdEncoding.EncodeToString([]byte("raw"))}); err != nil {
+			t.Fatal(err)
+		}
+		if messageType, data := read(first); messageType != websocket.BinaryMessage || data != "raw" {
+			t.Errorf("received %d %q, want decoded binary frame", messageType, data)
+		}
+		if err := h.sendWebSocket(ServerWebSocketSend{ConnectionID: firstID, Binary: true, Data: "not base64!"}); err == nil {
+			t.Errorf("sendWebSocket() should fail on invalid base64")
+		}
+	})
+
+	t.Run("broadcast", func(t *testing.T) {
+		if err := h.sendWebSocket(ServerWebSocketSend{Broadcast: true, Data: "all"}); err != nil {
+			t.Fatal(err)
+		}
+		for _, conn := range []*websocket.Conn{first, second} {
+			if _, data := read(conn); data != "all" {
+				t.Errorf("received %q, want broadcast data", data)
+			}
+		}
+	})
+
+	t.Run("close", func(t *testing.T) {
+		if err := h.sendWebSocket(ServerWebSocketSend{ConnectionID: firstID, Data: "bye", Close: true}); err != nil {
+			t.Fatal(err)
+		}
+		if _, data := read(first); data != "bye" {
+			t.Errorf("received %q, want data sent before close", data)
+		}
+		_ = first.SetReadDeadline(time.Now().Add(time.Second * 2))
+		if _, _, err := first.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+			t.Errorf("read error = %v, want normal closure", err)
+		}
+
+		event := receive(t, events)
+		if event.Event != WebSocketEventClose || event.ConnectionID != firstID {
+			t.Errorf("event = %s of %s, want close of the first connection", event.Event, event.ConnectionID)
+		}
+		if _, ok := h.wsConnections.Get(firstID); ok {
+			t.Errorf("closed connection should be forgotten")
+		}
+	})
+}
+
+func receive[T any](t *testing.T, ch chan T) T {
+	t.Helper()
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(time.Second * 2):
+		t.Fatalf("nothing received")
+	}
+	var zero T
+	return zero
+}